@@ -0,0 +1,430 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Sizes of the AIX big archive fixed length header, including the magic
+// number, and of the member headers without the name and trailer.
+const (
+	bigFileHeaderSize   = 128
+	bigMemberHeaderSize = 112
+)
+
+// bigFileHeader contains the offsets from the AIX big archive fixed length
+// header used for reading.
+type bigFileHeader struct {
+	gstoff   int64 // Offset to the 32 bit global symbol table.
+	gst64off int64 // Offset to the 64 bit global symbol table.
+	fstmoff  int64 // Offset to the first member.
+	lstmoff  int64 // Offset to the last member.
+}
+
+// readBigFileHeader reads the rest of the AIX big archive fixed length header
+// following the magic number.
+func (arr *Reader) readBigFileHeader() error {
+	hdr := make([]byte, bigFileHeaderSize-8)
+	_, err := io.ReadFull(arr.reader, hdr)
+	if err != nil {
+		return err
+	}
+
+	// The member table and free list offsets aren't needed.
+	names := []string{"memoff", "gstoff", "gst64off", "fstmoff", "lstmoff", "freeoff"}
+	fields := make([]int64, len(names))
+	for i, name := range names {
+		value := hdr[i*20 : (i+1)*20]
+
+		fields[i], err = parseBigField(arr.trimPad(value), 10)
+		if err != nil || fields[i] < 0 {
+			return &HeaderError{Offset: 0, Index: -1, Field: name, Value: value, Err: ErrHeader, Cause: err}
+		}
+	}
+
+	arr.big = &bigFileHeader{
+		gstoff:   fields[1],
+		gst64off: fields[2],
+		fstmoff:  fields[3],
+		lstmoff:  fields[4],
+	}
+	arr.bigNext = arr.big.fstmoff
+	arr.bigMembers = make(map[int64]string)
+
+	return nil
+}
+
+// nextBig advances to the next member of an AIX big archive by following the
+// member offsets, which must increase since the archive is read sequentially.
+// The global symbol tables are read after the last member.
+func (arr *Reader) nextBig() (*Header, error) {
+	offset := arr.bigNext
+	if offset == 0 {
+		return nil, arr.readBigSymbolTables()
+	}
+
+	last := offset == arr.big.lstmoff
+	header, next, err := arr.readBigHeader(offset, last)
+	if err != nil {
+		return nil, err
+	}
+
+	arr.bigNext = next
+	if last {
+		arr.bigNext = 0
+	}
+
+	arr.ur = header.Size
+	arr.bigMembers[offset] = header.Name
+
+	err = arr.countMember()
+	if err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// readBigHeader skips to offset and reads the member header there, returning
+// it with the offset to the next member. Unless it's the last member the next
+// offset must follow the header.
+func (arr *Reader) readBigHeader(offset int64, last bool) (*Header, int64, error) {
+	if offset < arr.reader.n {
+		return nil, 0, ErrHeader
+	}
+
+	err := arr.reader.skip(offset - arr.reader.n)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hdr := make([]byte, bigMemberHeaderSize)
+	_, err = io.ReadFull(arr.reader, hdr)
+	if err != nil {
+		return nil, 0, err
+	}
+	index := arr.index
+	arr.index++
+
+	pos := headerPos{offset: offset, index: index}
+
+	names := []string{"size", "nxtmem", "prvmem", "date", "uid", "gid", "mode", "namlen"}
+	values := [][]byte{hdr[:20], hdr[20:40], hdr[40:60], hdr[60:72],
+		hdr[72:84], hdr[84:96], hdr[96:108], hdr[108:112]}
+	fields := make([]int64, len(names))
+	for i, name := range names {
+		base := 10
+		if name == "mode" {
+			base = 8
+		}
+
+		fields[i], err = parseBigField(arr.trimPad(values[i]), base)
+		if err != nil && i >= 3 && i <= 6 {
+			// The date, uid, gid and mode can be defaulted.
+			err = arr.warn(pos.fieldError(name, values[i], ErrHeader, err))
+			if err != nil {
+				return nil, 0, err
+			}
+			fields[i] = 0
+		} else if err != nil {
+			return nil, 0, pos.fieldError(name, values[i], ErrHeader, err)
+		}
+
+		// Only the date may be negative.
+		if fields[i] < 0 && name != "date" {
+			return nil, 0, pos.fieldError(name, values[i], ErrHeader, nil)
+		}
+	}
+	if !last && fields[1] <= offset {
+		return nil, 0, pos.fieldError("nxtmem", values[1], ErrHeader, nil)
+	}
+	if arr.opts.MaxNameLength > 0 && fields[7] > arr.opts.MaxNameLength {
+		return nil, 0, pos.fieldError("namlen", values[7], ErrNameTooLong, nil)
+	}
+
+	// The name is padded to an even length and followed by the trailer.
+	nameLen := fields[7]
+	name := make([]byte, nameLen+nameLen%2+2)
+	_, err = io.ReadFull(arr.reader, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if string(name[len(name)-2:]) != "`\n" {
+		return nil, 0, pos.fieldError("fmag", name[len(name)-2:], ErrHeader, nil)
+	}
+	if arr.opts.MaxTotalSize > 0 && arr.reader.n+fields[0] > arr.opts.MaxTotalSize {
+		return nil, 0, pos.fieldError("size", values[0], ErrArchiveTooLarge, nil)
+	}
+
+	header := &Header{
+		Name:    string(name[:nameLen]),
+		ModTime: time.Unix(fields[3], 0),
+		Uid:     int(fields[4]),
+		Gid:     int(fields[5]),
+		Mode:    fields[6],
+		Size:    fields[0],
+	}
+
+	return header, fields[1], nil
+}
+
+// readBigSymbolTables reads the global symbol tables following the members
+// into a single table, resolving the member names. The tables for 32 and 64
+// bit objects both use 64 bit offsets.
+func (arr *Reader) readBigSymbolTables() error {
+	tables := []int64{arr.big.gstoff, arr.big.gst64off}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i] < tables[j]
+	})
+
+	for _, offset := range tables {
+		if offset == 0 || offset < arr.reader.n {
+			continue
+		}
+
+		header, _, err := arr.readBigHeader(offset, true)
+		if err != nil {
+			return err
+		}
+
+		arr.tableSize += header.Size
+		if arr.opts.MaxTableSize > 0 && arr.tableSize > arr.opts.MaxTableSize {
+			return ErrTableTooLarge
+		}
+
+		contents, err := readAll(arr.reader, header.Size)
+		if err != nil {
+			return err
+		}
+
+		symbols, err := parseGNUSymbolTable(contents, 8)
+		if err != nil {
+			return err
+		}
+
+		if arr.symbols == nil {
+			arr.symbols = newSymbolTable()
+		}
+		for _, sym := range symbols.Symbols {
+			arr.symbols.add(sym.Name, sym.Offset)
+		}
+	}
+
+	if arr.symbols != nil {
+		for offset, name := range arr.bigMembers {
+			arr.symbols.resolve(offset, name)
+		}
+	}
+
+	arr.big.gstoff = 0
+	arr.big.gst64off = 0
+	return nil
+}
+
+// parseBigField parses a numeric AIX big archive field, blank fields are zero.
+func parseBigField(field string, base int) (int64, error) {
+	if field == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(field, base, 64)
+}
+
+// createBigHeader creates the AIX big archive member header for a standard
+// file entry. The members are linked by their offsets, which are known since
+// the tables follow the members.
+func (arw *Writer) createBigHeader(header *Header) ([]byte, error) {
+	name := header.Name
+	nameLen := strconv.Itoa(len(name))
+	if len(nameLen) > 4 {
+		return nil, ErrHeaderTooLong
+	}
+
+	offset := bigFileHeaderSize + arw.buflen
+	prev := int64(0)
+	if len(arw.members) > 0 {
+		prev = bigFileHeaderSize + arw.members[len(arw.members)-1]
+	}
+	next := offset + bigMemberHeaderSize + int64(len(name)+len(name)%2+2) +
+		header.Size + header.Size%2
+
+	// Format the mode adding the regular file type if it's only permissions.
+	modeInt := header.Mode
+	if modeInt&^07777 == 0 {
+		modeInt |= 0100000
+	}
+
+	fields := []struct {
+		field string
+		width int
+	}{
+		{strconv.FormatInt(header.Size, 10), 20},
+		{strconv.FormatInt(next, 10), 20},
+		{strconv.FormatInt(prev, 10), 20},
+		{strconv.FormatInt(header.ModTime.Unix(), 10), 12},
+		{strconv.Itoa(header.Uid), 12},
+		{strconv.Itoa(header.Gid), 12},
+		{strconv.FormatInt(modeInt, 8), 12},
+		{nameLen, 4},
+	}
+
+	hdr := make([]byte, 0, bigMemberHeaderSize+len(name)+3)
+	for _, f := range fields {
+		if len(f.field) > f.width {
+			return nil, ErrHeaderTooLong
+		}
+
+		field := make([]byte, f.width)
+		arw.fillField(field, f.field)
+		hdr = append(hdr, field...)
+	}
+	hdr = append(hdr, name...)
+	if len(name)%2 != 0 {
+		hdr = append(hdr, 0)
+	}
+	hdr = append(hdr, "`\n"...)
+
+	// Set unwritten and padding.
+	arw.uw = header.Size
+	arw.pad = header.Size%2 != 0
+
+	// The names are kept for the member table.
+	arw.strings.WriteString(name + "\u0000")
+
+	// Start capturing the contents for the symbol table.
+	arw.offset = arw.buflen
+	arw.members = append(arw.members, arw.offset)
+	arw.magic = make([]byte, 0)
+	arw.name = header.Name
+
+	return hdr, nil
+}
+
+// writeAIXBig writes the fixed length header, the members, then the member
+// table and global symbol tables. Symbols of 64 bit XCOFF objects go in the
+// 64 bit table, and the rest in the 32 bit table like AIX ar.
+func (arw *Writer) writeAIXBig() error {
+	var err error
+
+	// Create the member table, the count and member offsets followed by the
+	// null terminated names.
+	var memTable bytes.Buffer
+	field := make([]byte, 20)
+	arw.fillField(field, strconv.Itoa(len(arw.members)))
+	memTable.Write(field)
+	for _, offset := range arw.members {
+		arw.fillField(field, strconv.FormatInt(bigFileHeaderSize+offset, 10))
+		memTable.Write(field)
+	}
+	arw.strings.WriteTo(&memTable)
+
+	// Create the global symbol tables.
+	symbols, symbols64 := make([]*entry, 0), make([]*entry, 0)
+	for _, entry := range arw.symbols {
+		if entry.Object64 {
+			symbols64 = append(symbols64, entry)
+		} else {
+			symbols = append(symbols, entry)
+		}
+	}
+	symTable := createBigSymbolTable(symbols)
+	symTable64 := createBigSymbolTable(symbols64)
+
+	// Calculate the offsets of the tables following the members, the 64 bit
+	// table follows the 32 bit table.
+	var memoff, gstoff, gst64off, fstmoff, lstmoff int64
+	if len(arw.members) > 0 {
+		fstmoff = bigFileHeaderSize
+		lstmoff = bigFileHeaderSize + arw.members[len(arw.members)-1]
+		memoff = bigFileHeaderSize + arw.buflen
+
+		next := memoff + bigMemberHeaderSize + 2 + int64(memTable.Len()+memTable.Len()%2)
+		if len(symbols) > 0 {
+			gstoff = next
+			next += bigMemberHeaderSize + 2 + int64(symTable.Len()+symTable.Len()%2)
+		}
+		if len(symbols64) > 0 {
+			gst64off = next
+		}
+	}
+
+	hdr := make([]byte, 0, bigFileHeaderSize)
+	hdr = append(hdr, "<bigaf>\n"...)
+	for _, offset := range []int64{memoff, gstoff, gst64off, fstmoff, lstmoff, 0} {
+		arw.fillField(field, strconv.FormatInt(offset, 10))
+		hdr = append(hdr, field...)
+	}
+
+	_, err = arw.writer.Write(hdr)
+	if err != nil {
+		return err
+	}
+
+	_, err = arw.buf.WriteTo(arw.writer)
+	if err != nil || memoff == 0 {
+		return err
+	}
+
+	err = arw.writeBigTable(&memTable, lstmoff, 0)
+	if err != nil {
+		return err
+	}
+
+	if gstoff != 0 {
+		err = arw.writeBigTable(symTable, 0, 0)
+		if err != nil {
+			return err
+		}
+	}
+	if gst64off != 0 {
+		err = arw.writeBigTable(symTable64, 0, 0)
+	}
+
+	return err
+}
+
+// createBigSymbolTable creates an AIX big archive global symbol table, the
+// count and member offsets as 64 bit integers followed by the null terminated
+// names.
+func createBigSymbolTable(symbols []*entry) *bytes.Buffer {
+	var table bytes.Buffer
+	putUint(binary.BigEndian, &table, 8, int64(len(symbols)))
+	for _, entry := range symbols {
+		putUint(binary.BigEndian, &table, 8, bigFileHeaderSize+entry.Offset)
+	}
+	for _, entry := range symbols {
+		table.WriteString(entry.Name + "\u0000")
+	}
+
+	return &table
+}
+
+// writeBigTable writes an AIX big archive table member with the contents of
+// table, linked to the members at prev and next.
+func (arw *Writer) writeBigTable(table *bytes.Buffer, prev, next int64) error {
+	hdr := make([]byte, bigMemberHeaderSize+2)
+	arw.fillField(hdr, "")
+	arw.fillField(hdr[:20], strconv.Itoa(table.Len()))
+	arw.fillField(hdr[20:40], strconv.FormatInt(next, 10))
+	arw.fillField(hdr[40:60], strconv.FormatInt(prev, 10))
+	for _, field := range [][]byte{hdr[60:72], hdr[72:84], hdr[84:96], hdr[96:108], hdr[108:112]} {
+		arw.fillField(field, "0")
+	}
+	copy(hdr[112:], "`\n")
+
+	_, err := arw.writer.Write(hdr)
+	if err != nil {
+		return err
+	}
+
+	if table.Len()%2 != 0 {
+		table.WriteByte('\n')
+	}
+	_, err = table.WriteTo(arw.writer)
+
+	return err
+}
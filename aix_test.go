@@ -0,0 +1,241 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestAIXBigWrite(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatAIXBig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exit, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, err := ioutil.ReadFile(filepath.Join("testdata", "hello.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"exit.o", "README", "a_rather_long_object_name.o"}
+	files := map[string][]byte{names[0]: exit, names[1]: []byte("odd length\n"), names[2]: hello}
+	for _, name := range names {
+		err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := out.Bytes()
+	if string(archive[:8]) != "<bigaf>\n" {
+		t.Fatal("Archive should start with the AIX big archive magic number.")
+	}
+
+	arReader := NewReader(bytes.NewReader(archive))
+	for _, name := range names {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil || header.Name != name {
+			t.Fatal("Reader should find the written entries.")
+		}
+		if header.Mode != 0100644 {
+			t.Error("Header mode isn't what it should be.")
+		}
+
+		contents, err := ioutil.ReadAll(arReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(contents, files[name]) {
+			t.Error("Entry contents aren't what they should be.")
+		}
+	}
+
+	header, err := arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != nil {
+		t.Error("Reader shouldn't return the member or symbol tables.")
+	}
+
+	if arReader.Format() != FormatAIXBig {
+		t.Error("Reader should detect the AIX big format.")
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 4 {
+		t.Fatal("Symbol table should contain the symbols from both objects.")
+	}
+	if symbols.Lookup("exit").Member != "exit.o" || symbols.Lookup("world").Member != "a_rather_long_object_name.o" {
+		t.Error("Symbols should resolve to their defining entries.")
+	}
+
+	ara, err := OpenReaderAt(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadAll(ara.Section(ara.Lookup("README")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(contents, files["README"]) {
+		t.Error("Section contents aren't what they should be.")
+	}
+	if ara.Symbols() == nil || ara.Symbols().Lookup("hello") == nil {
+		t.Error("ReaderAt should have the global symbol table.")
+	}
+}
+
+func TestAIXBigSymbolTables(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatAIXBig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"xcoff.o", "xcoff64.o", "exit.o"} {
+		contents, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(contents))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both tables use 64 bit counts and offsets, 64 bit XCOFF objects are in
+	// the 64 bit table and every other object in the 32 bit table.
+	archive := out.Bytes()
+	tables := map[string]struct {
+		field   int
+		count   uint64
+		members []int64
+	}{
+		"gstoff":   {28, 8, []int64{bigFileHeaderSize}},
+		"gst64off": {48, 2, nil},
+	}
+	for name, table := range tables {
+		offset, err := parseBigField(string(bytes.TrimRight(archive[table.field:table.field+20], " ")), 10)
+		if err != nil || offset == 0 {
+			t.Fatalf("Archive should have the %s table.", name)
+		}
+
+		contents := archive[offset+bigMemberHeaderSize+2:]
+		if binary.BigEndian.Uint64(contents) != table.count {
+			t.Errorf("The %s table should contain %d symbols.", name, table.count)
+		}
+		for i, member := range table.members {
+			if int64(binary.BigEndian.Uint64(contents[8+i*8:])) != member {
+				t.Errorf("The %s table should map to the member at offset %d.", name, member)
+			}
+		}
+	}
+
+	arReader, err := OpenReaderAt(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 10 {
+		t.Fatal("Symbol table should contain the symbols from both tables.")
+	}
+	if symbols.Lookup(".hello").Member != "xcoff.o" || symbols.Lookup("wide_fn").Member != "xcoff64.o" ||
+		symbols.Lookup("exit").Member != "exit.o" {
+		t.Error("Symbols should resolve to their defining entries.")
+	}
+}
+
+func TestAIXBigEmpty(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatAIXBig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != bigFileHeaderSize {
+		t.Error("Empty archive should only contain the fixed length header.")
+	}
+
+	header, err := NewReader(&out).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != nil {
+		t.Error("Empty archive shouldn't contain any entries.")
+	}
+}
+
+func TestAIXBigInvalidLink(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatAIXBig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"a", "b"} {
+		err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write([]byte(name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Point the first member's next offset back at itself.
+	archive := out.Bytes()
+	copy(archive[bigFileHeaderSize+20:bigFileHeaderSize+40], "128                 ")
+
+	arReader := NewReader(bytes.NewReader(archive))
+	_, err = arReader.Next()
+	var headerErr *HeaderError
+	if !errors.As(err, &headerErr) || headerErr.Field != "nxtmem" || headerErr.Offset != bigFileHeaderSize {
+		t.Error("Reader should reject member offsets that don't increase.")
+	}
+}
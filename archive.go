@@ -0,0 +1,345 @@
+package ar
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// Position is where Replace, Update and Move place file entries. The zero
+// value places new entries at the end, leaving existing entries in place.
+type Position struct {
+	Name  string // Name of the entry to place relative to.
+	After bool   // If entries go after Name instead of before it.
+}
+
+// Before returns the Position before the entry named name.
+func Before(name string) Position {
+	return Position{Name: name}
+}
+
+// After returns the Position after the entry named name.
+func After(name string) Position {
+	return Position{Name: name, After: true}
+}
+
+// Opener opens the contents of a file entry, Save calls it when the entry is
+// written and closes the contents after copying them.
+type Opener func() (io.ReadCloser, error)
+
+// FileOpener returns an Opener for the file at path, so it's only open while
+// Save copies it.
+func FileOpener(path string) Opener {
+	return func() (io.ReadCloser, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+
+		return file, nil
+	}
+}
+
+// archiveMember is a file entry and the source of its contents.
+type archiveMember struct {
+	header *Header
+	open   Opener
+}
+
+// Archive is an editable ar archive supporting the operations of the ar
+// command. Changes are only written by Save, which regenerates the symbol and
+// strings tables in the archives format.
+type Archive struct {
+	Format        Format // FormatCommon archives are saved as FormatGNU if a name is too long.
+	Deterministic bool   // Normalize headers when saving, see WriterOptions.
+	members       []*archiveMember
+}
+
+// NewArchive creates an empty Archive using format.
+func NewArchive(format Format) *Archive {
+	return &Archive{Format: format, members: make([]*archiveMember, 0)}
+}
+
+// OpenArchive creates an Archive from the archive read from r, which is size
+// bytes long, preserving its format and any Go metadata entries. The contents
+// of unchanged entries are read from r by Save. Thin archives need the path
+// to their entries, so Save returns ErrThinMember for them, see
+// OpenArchivePath.
+func OpenArchive(r io.ReaderAt, size int64) (*Archive, error) {
+	return OpenArchivePath(r, size, "")
+}
+
+// OpenArchivePath is like OpenArchive for the archive stored at path. The
+// contents of unchanged thin archive entries are opened relative to it by
+// Save, so the symbol table is regenerated from them, see ThinPath.
+func OpenArchivePath(r io.ReaderAt, size int64, path string) (*Archive, error) {
+	ara, err := OpenReaderAtOptions(r, size, ReaderOptions{GoMetadata: true})
+	if err != nil {
+		return nil, err
+	}
+	archive := NewArchive(ara.Format())
+
+	for _, header := range ara.Members() {
+		header := header
+		open := func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(ara.Section(header)), nil
+		}
+		if ara.Thin() {
+			open = func() (io.ReadCloser, error) {
+				if path == "" {
+					return nil, ErrThinMember
+				}
+
+				return FileOpener(ThinPath(path, header))()
+			}
+		}
+
+		archive.members = append(archive.members, &archiveMember{header: header, open: open})
+	}
+
+	return archive, nil
+}
+
+// Members returns the headers for the file entries in archive order.
+func (archive *Archive) Members() []*Header {
+	members := make([]*Header, len(archive.members))
+	for i, member := range archive.members {
+		members[i] = member.header
+	}
+
+	return members
+}
+
+// Replace replaces the entry with the same name as header with the contents
+// read from r, adding it if it doesn't exist like ar r. Replaced entries stay
+// in place unless pos names an entry. r isn't read until Save, and is only
+// read once, so use ReplaceOpener to save the archive more than once.
+func (archive *Archive) Replace(header *Header, r io.Reader, pos Position) error {
+	return archive.ReplaceOpener(header, readerOnce(r), pos)
+}
+
+// ReplaceOpener is like Replace but the contents are opened by open during
+// Save.
+func (archive *Archive) ReplaceOpener(header *Header, open Opener, pos Position) error {
+	member := &archiveMember{header: header, open: open}
+
+	i := archive.index(header.Name)
+	if i >= 0 && (pos.Name == "" || pos.Name == header.Name) {
+		archive.members[i] = member
+		return nil
+	}
+
+	// Validate the position before removing the old entry.
+	if pos.Name != "" && archive.index(pos.Name) < 0 {
+		return ErrMemberNotFound
+	}
+	if i >= 0 {
+		archive.remove(i)
+	}
+
+	return archive.insert(pos, member)
+}
+
+// Update is like Replace but only replaces existing entries if header has a
+// newer modification time, like ar ru. It reports whether the archive changed.
+// Like Replace, r is only read by the first Save.
+func (archive *Archive) Update(header *Header, r io.Reader, pos Position) (bool, error) {
+	return archive.UpdateOpener(header, readerOnce(r), pos)
+}
+
+// UpdateOpener is like Update but the contents are opened by open during
+// Save.
+func (archive *Archive) UpdateOpener(header *Header, open Opener, pos Position) (bool, error) {
+	i := archive.index(header.Name)
+	if i >= 0 && !header.ModTime.After(archive.members[i].header.ModTime) {
+		return false, nil
+	}
+
+	err := archive.ReplaceOpener(header, open, pos)
+	return err == nil, err
+}
+
+// Append adds an entry with the contents read from r to the end of the
+// archive without checking for existing entries, like ar q. r isn't read
+// until Save, and is only read once, so use AppendOpener to save the archive
+// more than once.
+func (archive *Archive) Append(header *Header, r io.Reader) {
+	archive.AppendOpener(header, readerOnce(r))
+}
+
+// AppendOpener is like Append but the contents are opened by open during
+// Save.
+func (archive *Archive) AppendOpener(header *Header, open Opener) {
+	archive.members = append(archive.members, &archiveMember{header: header, open: open})
+}
+
+// Delete removes the first entry for each name, like ar d. ErrMemberNotFound
+// is returned if any name isn't in the archive, the others are still removed.
+func (archive *Archive) Delete(names ...string) error {
+	var err error
+
+	for _, name := range names {
+		i := archive.index(name)
+		if i < 0 {
+			err = ErrMemberNotFound
+			continue
+		}
+
+		archive.remove(i)
+	}
+
+	return err
+}
+
+// Move moves the first entry for each name to pos in the order given, like ar
+// m. ErrMemberNotFound is returned if an entry or the entry for pos doesn't
+// exist, in which case the archive is unchanged.
+func (archive *Archive) Move(names []string, pos Position) error {
+	if pos.Name != "" && archive.index(pos.Name) < 0 {
+		return ErrMemberNotFound
+	}
+
+	// Find every entry before changing the archive, a repeated name moves the
+	// next entry with the name.
+	taken := make(map[int]bool)
+	indexes := make([]int, 0, len(names))
+	for _, name := range names {
+		i := -1
+		for j, member := range archive.members {
+			if member.header.Name == name && !taken[j] {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return ErrMemberNotFound
+		}
+
+		taken[i] = true
+		indexes = append(indexes, i)
+	}
+
+	moved := make([]*archiveMember, len(indexes))
+	for i, index := range indexes {
+		moved[i] = archive.members[index]
+	}
+
+	kept := make([]*archiveMember, 0, len(archive.members)-len(indexes))
+	for i, member := range archive.members {
+		if !taken[i] {
+			kept = append(kept, member)
+		}
+	}
+	archive.members = kept
+
+	// Moving the entry for pos places it at the end.
+	if pos.Name != "" && archive.index(pos.Name) < 0 {
+		pos = Position{}
+	}
+
+	for _, member := range moved {
+		err := archive.insert(pos, member)
+		if err != nil {
+			return err
+		}
+
+		pos = After(member.header.Name)
+	}
+
+	return nil
+}
+
+// Save writes the archive to w, regenerating the symbol and strings tables.
+// Each entry's contents are opened as it's written, and closed after.
+// io.ErrUnexpectedEOF is returned if they're shorter than the header's size,
+// and ErrWriteTooLong if they're longer.
+func (archive *Archive) Save(w io.Writer) error {
+	// Like GNU ar, switch to GNU long names if a name won't fit the header.
+	format := archive.Format
+	for _, member := range archive.members {
+		if format == FormatCommon && len(member.header.Name) > 16 {
+			format = FormatGNU
+		}
+	}
+
+	arw, err := NewWriterOptions(w, WriterOptions{
+		Format:        format,
+		Deterministic: archive.Deterministic,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, member := range archive.members {
+		err = arw.WriteHeader(member.header)
+		if err != nil {
+			return err
+		}
+
+		contents, err := member.open()
+		if err != nil {
+			return err
+		}
+
+		// Short contents would be padded with spaces by the Writer.
+		n, err := io.Copy(arw, contents)
+		if err == nil && n < member.header.Size {
+			err = io.ErrUnexpectedEOF
+		}
+		cerr := contents.Close()
+		if err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return arw.Close()
+}
+
+// index returns the index of the first entry named name, or -1.
+func (archive *Archive) index(name string) int {
+	for i, member := range archive.members {
+		if member.header.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// remove removes the entry at index i.
+func (archive *Archive) remove(i int) {
+	archive.members = append(archive.members[:i], archive.members[i+1:]...)
+}
+
+// insert inserts member at pos.
+func (archive *Archive) insert(pos Position, member *archiveMember) error {
+	i := len(archive.members)
+	if pos.Name != "" {
+		i = archive.index(pos.Name)
+		if i < 0 {
+			return ErrMemberNotFound
+		}
+
+		if pos.After {
+			i++
+		}
+	}
+
+	archive.members = append(archive.members, nil)
+	copy(archive.members[i+1:], archive.members[i:])
+	archive.members[i] = member
+
+	return nil
+}
+
+// readerOnce returns an Opener returning r, used for contents supplied by
+// callers which are left open. r is consumed by the first Save, later ones
+// return io.ErrUnexpectedEOF for the entry.
+func readerOnce(r io.Reader) Opener {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(r), nil
+	}
+}
@@ -0,0 +1,345 @@
+package ar
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestArchive(t *testing.T, file string) *Archive {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := OpenArchive(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return archive
+}
+
+func memberNames(archive *Archive) string {
+	names := make([]string, 0)
+	for _, header := range archive.Members() {
+		names = append(names, header.Name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+func TestArchiveEdit(t *testing.T) {
+	archive := openTestArchive(t, "gnu_symbols.a")
+	if memberNames(archive) != "exit.o,hello.o" {
+		t.Fatal("Archive should contain the test objects.")
+	}
+
+	notes := []byte("notes\n")
+	archive.Append(&Header{Name: "notes.txt", Mode: 0644, Size: int64(len(notes))}, bytes.NewReader(notes))
+	if memberNames(archive) != "exit.o,hello.o,notes.txt" {
+		t.Error("Append should add the entry at the end.")
+	}
+
+	err := archive.Move([]string{"notes.txt"}, Before("exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memberNames(archive) != "notes.txt,exit.o,hello.o" {
+		t.Error("Move should place the entry before exit.o.")
+	}
+
+	err = archive.Move([]string{"exit.o"}, After("hello.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memberNames(archive) != "notes.txt,hello.o,exit.o" {
+		t.Error("Move should place the entry after hello.o.")
+	}
+
+	err = archive.Delete("exit.o", "missing.o")
+	if err != ErrMemberNotFound {
+		t.Error("Delete should return ErrMemberNotFound for missing entries.")
+	}
+	if memberNames(archive) != "notes.txt,hello.o" {
+		t.Error("Delete should remove the existing entry.")
+	}
+
+	updated, err := archive.Update(&Header{Name: "hello.o", Size: 1}, bytes.NewReader([]byte("x")), Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Error("Update shouldn't replace entries with older modification times.")
+	}
+
+	replaced := []byte("replaced notes\n")
+	err = archive.Replace(&Header{Name: "notes.txt", Mode: 0644, Size: int64(len(replaced))}, bytes.NewReader(replaced), Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = archive.Save(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader := NewReader(&out)
+	header, err := arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || header.Name != "notes.txt" {
+		t.Fatal("Saved archive should start with notes.txt.")
+	}
+
+	contents, err := ioutil.ReadAll(arReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(contents, replaced) {
+		t.Error("Saved entry should have the replaced contents.")
+	}
+
+	header, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || header.Name != "hello.o" {
+		t.Fatal("Saved archive should contain hello.o.")
+	}
+
+	symbols := arReader.Symbols()
+	if len(symbols.Symbols) != 3 || symbols.Lookup("exit") != nil {
+		t.Error("Symbol table should be regenerated without the deleted entry.")
+	}
+}
+
+func TestArchiveUpdate(t *testing.T) {
+	archive := openTestArchive(t, "bsd_symbols.a")
+
+	info, err := os.Stat(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := []byte("newer")
+	header := &Header{Name: "exit.o", ModTime: time.Now(), Mode: 0644, Size: int64(len(contents))}
+	updated, err := archive.Update(header, bytes.NewReader(contents), Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated || info.Size() == archive.Members()[0].Size {
+		t.Error("Update should replace entries with older modification times.")
+	}
+
+	var out bytes.Buffer
+	err = archive.Save(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if arReader.Format() != FormatBSD {
+		t.Error("Save should preserve the archive format.")
+	}
+
+	if arReader.Symbols().Lookup("exit") != nil {
+		t.Error("Symbol table should be regenerated without the replaced object.")
+	}
+}
+
+// trackedFile counts the times the contents of an entry are closed.
+type trackedFile struct {
+	io.Reader
+	closed *int
+}
+
+func (tf *trackedFile) Close() error {
+	*tf.closed++
+	return nil
+}
+
+func TestArchiveOpener(t *testing.T) {
+	archive := NewArchive(FormatGNU)
+
+	opened, closed := 0, 0
+	for _, name := range []string{"a.txt", "b.txt"} {
+		name := name
+		header := &Header{Name: name, Mode: 0644, Size: int64(len(name))}
+		archive.AppendOpener(header, func() (io.ReadCloser, error) {
+			if opened != closed {
+				t.Error("The previous entry should be closed before the next is opened.")
+			}
+			opened++
+
+			return &trackedFile{Reader: strings.NewReader(name), closed: &closed}, nil
+		})
+	}
+
+	err := archive.ReplaceOpener(&Header{Name: "exit.o", Mode: 0644}, FileOpener(filepath.Join("testdata", "missing.o")), Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened != 0 {
+		t.Error("Contents shouldn't be opened until Save.")
+	}
+
+	err = archive.Save(ioutil.Discard)
+	if !os.IsNotExist(err) {
+		t.Error("Save should return the error opening the contents.")
+	}
+	if opened != 2 || closed != 2 {
+		t.Error("Save should close the contents after copying them.")
+	}
+}
+
+func TestArchiveMoveMissing(t *testing.T) {
+	archive := openTestArchive(t, "gnu_symbols.a")
+
+	err := archive.Move([]string{"exit.o", "missing.o"}, Position{})
+	if err != ErrMemberNotFound {
+		t.Error("Move should return ErrMemberNotFound for missing entries.")
+	}
+	if memberNames(archive) != "exit.o,hello.o" {
+		t.Error("Move shouldn't change the archive if an entry is missing.")
+	}
+
+	err = archive.Move([]string{"hello.o", "exit.o"}, Position{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memberNames(archive) != "hello.o,exit.o" {
+		t.Error("Move should place the entries in the order given.")
+	}
+}
+
+func TestArchiveCommon(t *testing.T) {
+	tests := map[string]string{
+		filepath.Join("corpus", "go_pack.a"):                "__.PKGDEF,_go_.o,exit.o",
+		filepath.Join("..", "deb", "testdata", "hello.deb"): "debian-binary,control.tar.gz,data.tar.gz",
+	}
+
+	for path, names := range tests {
+		archive := openTestArchive(t, path)
+		if archive.Format != FormatCommon {
+			t.Errorf("Archive %s should be detected as the common format.", path)
+		}
+		if memberNames(archive) != names {
+			t.Errorf("Archive %s should keep every entry, including Go metadata.", path)
+		}
+
+		var out bytes.Buffer
+		err := archive.Save(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		saved, err := OpenArchive(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if saved.Format != FormatCommon || memberNames(saved) != names {
+			t.Errorf("Saving %s should preserve the common format and entries.", path)
+		}
+	}
+}
+
+func TestArchiveThin(t *testing.T) {
+	path := filepath.Join("testdata", "thin_test.a")
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := OpenArchive(bytes.NewReader(contents), int64(len(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = archive.Save(ioutil.Discard)
+	if err != ErrThinMember {
+		t.Error("Saving a thin archive without its path should return ErrThinMember.")
+	}
+
+	archive, err = OpenArchivePath(bytes.NewReader(contents), int64(len(contents)), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = archive.Save(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Thin() {
+		t.Error("Saving should preserve the thin format.")
+	}
+
+	// The symbols are extracted from the referenced files.
+	symbols := saved.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 4 || symbols.Lookup("hello").Member != "hello.o" {
+		t.Error("Saving should regenerate the symbol table from the referenced files.")
+	}
+}
+
+func TestArchiveShortContents(t *testing.T) {
+	archive := NewArchive(FormatGNU)
+	archive.Append(&Header{Name: "short.txt", Mode: 0644, Size: 10}, strings.NewReader("short"))
+
+	err := archive.Save(ioutil.Discard)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Save should return io.ErrUnexpectedEOF for short contents, got %v.", err)
+	}
+
+	// Readers are consumed by the first Save.
+	archive = NewArchive(FormatGNU)
+	archive.Append(&Header{Name: "once.txt", Mode: 0644, Size: 4}, strings.NewReader("once"))
+
+	err = archive.Save(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = archive.Save(ioutil.Discard)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("Saving a read entry again should return io.ErrUnexpectedEOF, got %v.", err)
+	}
+}
+
+func TestArchiveCommonLongName(t *testing.T) {
+	archive := openTestArchive(t, filepath.Join("corpus", "go_pack.a"))
+	archive.Append(&Header{Name: "a_rather_long_object_name.o", Mode: 0644, Size: 4}, strings.NewReader("long"))
+
+	var out bytes.Buffer
+	err := archive.Save(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := OpenArchive(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Format != FormatGNU {
+		t.Error("Saving a long name should switch to the GNU format.")
+	}
+	if memberNames(saved) != "__.PKGDEF,_go_.o,exit.o,a_rather_long_object_name.o" {
+		t.Errorf("Saved entries are %s.", memberNames(saved))
+	}
+}
@@ -0,0 +1,385 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	errBitcode = errors.New("ar: invalid LLVM bitcode")
+)
+
+// bitcodeMagic identifies LLVM bitcode, and bitcodeWrapperMagic the wrapper
+// header Darwin targets put before it.
+var (
+	bitcodeMagic        = []byte("BC\xc0\xde")
+	bitcodeWrapperMagic = []byte{0xde, 0xc0, 0x17, 0x0b}
+)
+
+// LLVM bitstream and bitcode constants, from llvm/Bitstream/BitCodes.h and
+// llvm/Bitcode/LLVMBitCodes.h.
+const (
+	bitcodeAbbrevWidth = 2 // Abbreviation ID width outside of blocks.
+
+	bitcodeEndBlock      = 0
+	bitcodeEnterSubblock = 1
+	bitcodeDefineAbbrev  = 2
+	bitcodeUnabbrev      = 3
+	bitcodeFirstAbbrev   = 4
+
+	bitcodeLiteral = 0 // Literal abbreviation operands, encodings start at 1.
+	bitcodeFixed   = 1
+	bitcodeVBR     = 2
+	bitcodeArray   = 3
+	bitcodeChar6   = 4
+	bitcodeBlob    = 5
+
+	bitcodeStrtabBlock = 23 // STRTAB_BLOCK_ID.
+	bitcodeSymtabBlock = 25 // SYMTAB_BLOCK_ID.
+	bitcodeBlobRecord  = 1  // STRTAB_BLOB and SYMTAB_BLOB.
+)
+
+// Layout of the irsymtab in the SYMTAB block, from llvm/Object/IRSymtab.h.
+// Names are offsets and sizes into the STRTAB block.
+const (
+	irsymtabSymbolRange = 28 // Offset to the symbols offset and count.
+	irsymtabSymbolSize  = 24 // Name, IR name, comdat index and flags.
+	irsymtabFlags       = 20 // Offset to the flags in a symbol.
+
+	irsymtabUndefined      = 1 << 3
+	irsymtabGlobal         = 1 << 10
+	irsymtabFormatSpecific = 1 << 11
+)
+
+// bitcodeSymbols returns the names of the global symbols defined by the LLVM
+// bitcode contents, from the irsymtab LLVM writes alongside the modules. The
+// symbols match the ones llvm-ar adds to the archive index, bitcode without
+// an irsymtab is invalid since the modules themselves aren't parsed.
+func bitcodeSymbols(name string, contents []byte) ([]string, error) {
+	if bytes.HasPrefix(contents, bitcodeWrapperMagic) {
+		// Magic, version, offset, size and CPU type.
+		if len(contents) < 20 {
+			return nil, errBitcode
+		}
+
+		offset := int64(binary.LittleEndian.Uint32(contents[8:]))
+		size := int64(binary.LittleEndian.Uint32(contents[12:]))
+		if offset+size > int64(len(contents)) {
+			return nil, errBitcode
+		}
+
+		contents = contents[offset : offset+size]
+	}
+	if !bytes.HasPrefix(contents, bitcodeMagic) {
+		return nil, errBitcode
+	}
+
+	// Only the top level blocks are read, skipping all but the tables. Fewer
+	// bits than a block header are trailing padding.
+	var symtab, strtab []byte
+	br := &bitReader{b: contents, pos: int64(len(bitcodeMagic)) * 8}
+	for br.end()-br.pos >= 64 && br.err == nil {
+		if br.read(bitcodeAbbrevWidth) != bitcodeEnterSubblock {
+			return nil, errBitcode
+		}
+
+		id, width, end := br.enterBlock()
+		switch id {
+		case bitcodeSymtabBlock:
+			symtab = br.blockBlob(width, end)
+		case bitcodeStrtabBlock:
+			strtab = br.blockBlob(width, end)
+		}
+		br.seek(end)
+	}
+	if br.err != nil {
+		return nil, br.err
+	}
+	if symtab == nil || strtab == nil {
+		return nil, errBitcode
+	}
+
+	return irsymtabSymbols(symtab, strtab)
+}
+
+// irsymtabSymbols returns the names of the global, defined symbols in
+// symtab that aren't specific to the object format, like llvm.used.
+func irsymtabSymbols(symtab, strtab []byte) ([]string, error) {
+	if len(symtab) < irsymtabSymbolRange+8 {
+		return nil, errBitcode
+	}
+
+	offset := int64(binary.LittleEndian.Uint32(symtab[irsymtabSymbolRange:]))
+	count := int64(binary.LittleEndian.Uint32(symtab[irsymtabSymbolRange+4:]))
+	if offset+count*irsymtabSymbolSize > int64(len(symtab)) {
+		return nil, errBitcode
+	}
+
+	names := make([]string, 0)
+	for i := int64(0); i < count; i++ {
+		sym := symtab[offset+i*irsymtabSymbolSize:]
+		flags := binary.LittleEndian.Uint32(sym[irsymtabFlags:])
+		if flags&(irsymtabUndefined|irsymtabFormatSpecific) != 0 || flags&irsymtabGlobal == 0 {
+			continue
+		}
+
+		start := int64(binary.LittleEndian.Uint32(sym))
+		size := int64(binary.LittleEndian.Uint32(sym[4:]))
+		if start+size > int64(len(strtab)) {
+			return nil, errBitcode
+		}
+
+		names = append(names, string(strtab[start:start+size]))
+	}
+
+	return names, nil
+}
+
+// bitcodeAbbrevOp is an operand of an abbreviation, either a literal value or
+// an encoding with its width.
+type bitcodeAbbrevOp struct {
+	encoding uint64
+	value    uint64
+}
+
+// bitReader reads the LLVM bitstream in b, which is stored in little endian
+// 32 bit words read from the least significant bit. The first error is kept
+// in err and zero values are returned after it.
+type bitReader struct {
+	b   []byte
+	pos int64 // Offset in bits.
+	err error
+}
+
+// end returns the offset in bits to the end of the stream.
+func (br *bitReader) end() int64 {
+	return int64(len(br.b)) * 8
+}
+
+// read reads a fixed width field of up to 64 bits.
+func (br *bitReader) read(width uint64) uint64 {
+	if br.err != nil || width > 64 || br.pos+int64(width) > br.end() {
+		br.err = errBitcode
+		return 0
+	}
+
+	var v uint64
+	for i := uint64(0); i < width; i++ {
+		bit := br.b[br.pos/8] >> uint(br.pos%8) & 1
+		v |= uint64(bit) << i
+		br.pos++
+	}
+
+	return v
+}
+
+// vbr reads a variable width field made of width bit chunks, each using its
+// high bit to mark that another chunk follows.
+func (br *bitReader) vbr(width uint64) uint64 {
+	if width < 2 || width > 32 {
+		br.err = errBitcode
+		return 0
+	}
+
+	var v uint64
+	for shift := uint64(0); br.err == nil; shift += width - 1 {
+		if shift > 63 {
+			br.err = errBitcode
+			break
+		}
+
+		chunk := br.read(width)
+		v |= (chunk & (1<<(width-1) - 1)) << shift
+		if chunk&(1<<(width-1)) == 0 {
+			break
+		}
+	}
+
+	return v
+}
+
+// align moves to the next 32 bit word boundary.
+func (br *bitReader) align() {
+	br.seek((br.pos + 31) &^ 31)
+}
+
+// seek moves to the offset pos in bits.
+func (br *bitReader) seek(pos int64) {
+	if pos > br.end() {
+		br.err = errBitcode
+		return
+	}
+
+	br.pos = pos
+}
+
+// enterBlock reads the rest of an ENTER_SUBBLOCK, returning the block ID,
+// the abbreviation ID width, and the offset in bits to the end of the block.
+func (br *bitReader) enterBlock() (uint64, uint64, int64) {
+	id := br.vbr(8)
+	width := br.vbr(4)
+	br.align()
+	words := br.read(32)
+
+	end := br.pos + int64(words)*32
+	if end > br.end() {
+		br.err = errBitcode
+	}
+
+	return id, width, end
+}
+
+// blockBlob reads the records of the block ending at end, returning the blob
+// of the last blob record in the block.
+func (br *bitReader) blockBlob(width uint64, end int64) []byte {
+	var blob []byte
+	abbrevs := make([][]bitcodeAbbrevOp, 0)
+
+	for br.err == nil {
+		if br.pos >= end {
+			br.err = errBitcode
+			break
+		}
+
+		switch id := br.read(width); id {
+		case bitcodeEndBlock:
+			br.align()
+			return blob
+		case bitcodeEnterSubblock:
+			_, _, subEnd := br.enterBlock()
+			br.seek(subEnd)
+		case bitcodeDefineAbbrev:
+			abbrevs = append(abbrevs, br.defineAbbrev())
+		case bitcodeUnabbrev:
+			br.vbr(6)
+			ops := br.vbr(6)
+			for i := uint64(0); i < ops && br.err == nil; i++ {
+				br.vbr(6)
+			}
+		default:
+			if id-bitcodeFirstAbbrev >= uint64(len(abbrevs)) {
+				br.err = errBitcode
+				break
+			}
+
+			code, b := br.abbrevRecord(abbrevs[id-bitcodeFirstAbbrev])
+			if code == bitcodeBlobRecord && b != nil {
+				blob = b
+			}
+		}
+	}
+
+	return nil
+}
+
+// defineAbbrev reads the rest of a DEFINE_ABBREV. Arrays must be followed by
+// their element operand and end the abbreviation like blobs.
+func (br *bitReader) defineAbbrev() []bitcodeAbbrevOp {
+	count := br.vbr(5)
+	ops := make([]bitcodeAbbrevOp, 0)
+
+	for i := uint64(0); i < count && br.err == nil; i++ {
+		if br.read(1) == 1 {
+			ops = append(ops, bitcodeAbbrevOp{encoding: bitcodeLiteral, value: br.vbr(8)})
+			continue
+		}
+
+		op := bitcodeAbbrevOp{encoding: br.read(3)}
+		switch op.encoding {
+		case bitcodeFixed, bitcodeVBR:
+			op.value = br.vbr(5)
+			if op.value > 32 {
+				br.err = errBitcode
+			}
+
+			// Zero width fields are literal zeroes.
+			if op.value == 0 {
+				op.encoding = bitcodeLiteral
+			}
+		case bitcodeArray:
+			if i != count-2 {
+				br.err = errBitcode
+			}
+		case bitcodeBlob:
+			if i != count-1 {
+				br.err = errBitcode
+			}
+		case bitcodeChar6:
+		default:
+			br.err = errBitcode
+		}
+
+		ops = append(ops, op)
+	}
+
+	if len(ops) > 1 && ops[len(ops)-2].encoding == bitcodeArray {
+		element := ops[len(ops)-1].encoding
+		if element == bitcodeArray || element == bitcodeBlob {
+			br.err = errBitcode
+		}
+	}
+
+	return ops
+}
+
+// abbrevRecord reads a record using abbrev, returning the record code and
+// the blob if it has one. Other operands are skipped.
+func (br *bitReader) abbrevRecord(abbrev []bitcodeAbbrevOp) (uint64, []byte) {
+	var code uint64
+	var blob []byte
+
+	for i := 0; i < len(abbrev) && br.err == nil; i++ {
+		var v uint64
+
+		switch op := abbrev[i]; op.encoding {
+		case bitcodeArray:
+			n := br.vbr(6)
+			if int64(n) < 0 || int64(n) > br.end()-br.pos {
+				br.err = errBitcode
+				break
+			}
+
+			for j := uint64(0); j < n && br.err == nil; j++ {
+				br.abbrevScalar(abbrev[i+1])
+			}
+			i++
+		case bitcodeBlob:
+			n := br.vbr(6)
+			br.align()
+			if int64(n) < 0 || int64(n) > (br.end()-br.pos)/8 {
+				br.err = errBitcode
+				break
+			}
+
+			blob = br.b[br.pos/8 : br.pos/8+int64(n)]
+			br.seek(br.pos + int64(n)*8)
+			br.align()
+		default:
+			v = br.abbrevScalar(op)
+		}
+
+		if i == 0 {
+			code = v
+		}
+	}
+
+	return code, blob
+}
+
+// abbrevScalar reads a literal, fixed, VBR or char6 operand.
+func (br *bitReader) abbrevScalar(op bitcodeAbbrevOp) uint64 {
+	switch op.encoding {
+	case bitcodeLiteral:
+		return op.value
+	case bitcodeFixed:
+		return br.read(op.value)
+	case bitcodeVBR:
+		return br.vbr(op.value)
+	case bitcodeChar6:
+		return br.read(6)
+	}
+
+	br.err = errBitcode
+	return 0
+}
@@ -0,0 +1,104 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBitcodeSymbols(t *testing.T) {
+	// The same symbols llvm-ar adds to the archive index, the Darwin bitcode
+	// has a wrapper header and mangled names.
+	tests := map[string][]string{
+		"bitcode.bc":        {"hello", "counter", "shared", "hidden_value", "weak_value"},
+		"bitcode_darwin.bc": {"_hello", "_counter", "_shared", "_hidden_value", "_weak_value"},
+	}
+
+	for name, expected := range tests {
+		contents, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names, err := bitcodeSymbols(name, contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("Symbols for %s are %v, expected %v.", name, names, expected)
+		}
+	}
+}
+
+func TestBitcodeWrite(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatBSD})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"bitcode.bc", "bitcode_darwin.bc"}
+	for _, name := range names {
+		contents, err := ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(contents))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 10 {
+		t.Fatal("Symbol table should contain the symbols from both modules.")
+	}
+	if symbols.Lookup("hello").Member != "bitcode.bc" || symbols.Lookup("_hello").Member != "bitcode_darwin.bc" {
+		t.Error("Symbols should resolve to their defining entries.")
+	}
+}
+
+func TestInvalidBitcode(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "bitcode_darwin.bc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncating the wrapper or the bitcode it wraps removes the tables, the
+	// wrapper padding following the bitcode isn't needed.
+	end := int(binary.LittleEndian.Uint32(contents[8:]) + binary.LittleEndian.Uint32(contents[12:]))
+	for i := 0; i < end; i++ {
+		_, err = bitcodeSymbols("bitcode_darwin.bc", contents[:i])
+		if err != errBitcode {
+			t.Fatalf("Bitcode truncated to %d bytes should be invalid.", i)
+		}
+	}
+
+	// Bitcode without an irsymtab, like llvm-as writes for modules without a
+	// data layout.
+	noSymtab := []byte("BC\xc0\xde\x35\x14\x00\x00\x00\x00\x00\x00")
+	_, err = bitcodeSymbols("empty.bc", noSymtab)
+	if err != errBitcode {
+		t.Error("Bitcode without an irsymtab should be invalid.")
+	}
+}
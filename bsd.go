@@ -0,0 +1,98 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// Names of the sorted BSD ranlib symbol tables, using 32 and 64 bit fields.
+const (
+	bsdSymbolTableName   = "__.SYMDEF SORTED"
+	bsdSymbolTable64Name = "__.SYMDEF_64 SORTED"
+)
+
+// bsdName pads name with null bytes so the contents following the header at
+// offset begin on an 8 byte boundary.
+func bsdName(name string, offset int64) []byte {
+	padded := []byte(name)
+	for len(padded) <= len(name) || (offset+60+int64(len(padded)))%8 != 0 {
+		padded = append(padded, 0)
+	}
+
+	return padded
+}
+
+// writeBSDTables writes the magic number, and the BSD ranlib symbol table
+// sorted by symbol name. The 64 bit table is used if an offset won't fit.
+func (arw *Writer) writeBSDTables() error {
+	symbols := make([]*entry, len(arw.symbols))
+	copy(symbols, arw.symbols)
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].Name < symbols[j].Name
+	})
+
+	// Create the strings table, padded so the table is 8 byte aligned.
+	var strTable bytes.Buffer
+	strx := make([]int, len(symbols))
+	for i, entry := range symbols {
+		strx[i] = strTable.Len()
+		strTable.WriteString(entry.Name + "\u0000")
+	}
+	for strTable.Len()%8 != 0 {
+		strTable.WriteByte(0)
+	}
+
+	// Calculate the size of the data before file entries, used to complete
+	// the symbol offsets.
+	width := int64(4)
+	tableName := bsdSymbolTableName
+	tableSize := 2*width + (2 * width * int64(len(symbols))) + int64(strTable.Len())
+	size := 8 + 60 + int64(len(bsdName(tableName, 8))) + tableSize
+	if exceedsUint32(symbols, size) {
+		width = 8
+		tableName = bsdSymbolTable64Name
+		tableSize = 2*width + (2 * width * int64(len(symbols))) + int64(strTable.Len())
+		size = 8 + 60 + int64(len(bsdName(tableName, 8))) + tableSize
+	}
+
+	// Create the symbol table.
+	var symTable bytes.Buffer
+	putUint(binary.LittleEndian, &symTable, width, 2*width*int64(len(symbols)))
+	for i, entry := range symbols {
+		putUint(binary.LittleEndian, &symTable, width, int64(strx[i]))
+		putUint(binary.LittleEndian, &symTable, width, entry.Offset+size)
+	}
+	putUint(binary.LittleEndian, &symTable, width, int64(strTable.Len()))
+	_, err := strTable.WriteTo(&symTable)
+	if err != nil {
+		return err
+	}
+
+	// Create symbol header.
+	symHeader, err := arw.createHeader(false, &Header{
+		Name:    tableName,
+		ModTime: arw.tableTime(),
+		Uid:     0,
+		Gid:     0,
+		Mode:    0,
+		Size:    tableSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = arw.writer.Write([]byte("!<arch>\n"))
+	if err != nil {
+		return err
+	}
+
+	_, err = arw.writer.Write(symHeader)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(arw.writer, &symTable)
+	return err
+}
@@ -0,0 +1,533 @@
+// Command ar creates, modifies, and extracts from ar archives. It implements
+// the POSIX key letters with the output formats and exit codes of GNU ar.
+//
+// Usage:
+//
+//	ar [-]{dmpqrstx}[abciDosuUv] [relpos] archive [member...]
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/larzconwell/ar"
+)
+
+const usage = `Usage: ar [-]{dmpqrstx}[abciDosuUv] [relpos] archive [member...]
+ commands:
+  d  - delete file(s) from the archive
+  m  - move file(s) in the archive
+  p  - print file(s) found in the archive
+  q  - quick append file(s) to the archive
+  r  - replace existing or insert new file(s) into the archive
+  s  - act as ranlib
+  t  - display contents of the archive
+  x  - extract file(s) from the archive
+ modifiers:
+  a  - put file(s) after [relpos]
+  b  - put file(s) before [relpos] (same as i)
+  c  - do not warn if the library had to be created
+  D  - use zero for timestamps and uids/gids (default)
+  o  - preserve original dates
+  s  - create an archive index (always done)
+  u  - only replace files that are newer than current archive contents
+  U  - use actual timestamps and uids/gids
+  v  - be verbose
+`
+
+// Exit codes used by GNU ar.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitNoFile  = 9
+)
+
+var errUsage = errors.New("usage")
+
+// options contains the parsed key letters.
+type options struct {
+	op            byte
+	pos           ar.Position
+	positioned    bool
+	create        bool
+	deterministic bool
+	preserve      bool
+	update        bool
+	verbose       bool
+}
+
+// command is a single invocation of ar.
+type command struct {
+	opts    options
+	archive string
+	members []string
+	stdout  io.Writer
+	stderr  io.Writer
+	status  int
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs ar with args, returning the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	cmd, err := parseArgs(args)
+	if err != nil {
+		if err != errUsage {
+			fmt.Fprintf(stderr, "ar: %v\n", err)
+		}
+
+		fmt.Fprint(stderr, usage)
+		return exitFailure
+	}
+	cmd.stdout = stdout
+	cmd.stderr = stderr
+
+	switch cmd.opts.op {
+	case 'd':
+		cmd.edit(cmd.delete)
+	case 'm':
+		cmd.edit(cmd.move)
+	case 'p':
+		cmd.read(cmd.print)
+	case 'q':
+		cmd.write(cmd.quickAppend)
+	case 'r':
+		cmd.write(cmd.replace)
+	case 's':
+		cmd.edit(func(*ar.Archive) error { return nil })
+	case 't':
+		cmd.read(cmd.list)
+	case 'x':
+		cmd.read(cmd.extract)
+	}
+
+	return cmd.status
+}
+
+// parseArgs parses the key letters, relpos, archive and members from args.
+func parseArgs(args []string) (*command, error) {
+	if len(args) < 2 {
+		return nil, errUsage
+	}
+	cmd := &command{opts: options{deterministic: true}}
+	key := strings.TrimPrefix(args[0], "-")
+	args = args[1:]
+
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+
+		switch c {
+		case 'd', 'm', 'p', 'q', 'r', 't', 'x':
+			if cmd.opts.op != 0 && cmd.opts.op != 's' {
+				return nil, errors.New("two different operation options specified")
+			}
+			cmd.opts.op = c
+		case 's':
+			if cmd.opts.op == 0 {
+				cmd.opts.op = 's'
+			}
+		case 'a', 'b', 'i':
+			cmd.opts.positioned = true
+			cmd.opts.pos.After = c == 'a'
+		case 'c':
+			cmd.opts.create = true
+		case 'D':
+			cmd.opts.deterministic = true
+		case 'U':
+			cmd.opts.deterministic = false
+		case 'o':
+			cmd.opts.preserve = true
+		case 'u':
+			cmd.opts.update = true
+		case 'v':
+			cmd.opts.verbose = true
+		default:
+			return nil, fmt.Errorf("invalid option -- '%c'", c)
+		}
+	}
+
+	if cmd.opts.op == 0 {
+		return nil, errors.New("no operation specified")
+	}
+
+	if cmd.opts.positioned {
+		if cmd.opts.op != 'm' && cmd.opts.op != 'r' {
+			return nil, errUsage
+		}
+		if len(args) < 2 {
+			return nil, errUsage
+		}
+
+		cmd.opts.pos.Name = args[0]
+		args = args[1:]
+	}
+
+	cmd.archive = args[0]
+	cmd.members = args[1:]
+	return cmd, nil
+}
+
+// fail reports err and sets the exit code.
+func (cmd *command) fail(status int, format string, a ...interface{}) {
+	fmt.Fprintf(cmd.stderr, "ar: "+format+"\n", a...)
+	cmd.status = status
+}
+
+// open opens the archive, reporting missing archives and invalid formats.
+func (cmd *command) open() (*os.File, *ar.ReaderAt, bool) {
+	file, err := os.Open(cmd.archive)
+	if err != nil {
+		if os.IsNotExist(err) {
+			cmd.fail(exitNoFile, "%s: No such file or directory", cmd.archive)
+		} else {
+			cmd.fail(exitFailure, "%s: %v", cmd.archive, err)
+		}
+
+		return nil, nil, false
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		cmd.fail(exitFailure, "%s: %v", cmd.archive, err)
+		return nil, nil, false
+	}
+
+	arReader, err := ar.OpenReaderAt(file, stat.Size())
+	if err != nil {
+		file.Close()
+		cmd.fail(exitFailure, "%s: file format not recognized", cmd.archive)
+		return nil, nil, false
+	}
+
+	return file, arReader, true
+}
+
+// selected returns the headers matching the member arguments, or every header
+// if there are none. Missing members are reported without failing.
+func (cmd *command) selected(arReader *ar.ReaderAt) []*ar.Header {
+	if len(cmd.members) == 0 {
+		return arReader.Members()
+	}
+
+	headers := make([]*ar.Header, 0, len(cmd.members))
+	for _, name := range cmd.members {
+		header := arReader.Lookup(name)
+		if header == nil {
+			fmt.Fprintf(cmd.stderr, "no entry %s in archive\n", name)
+			continue
+		}
+
+		headers = append(headers, header)
+	}
+
+	return headers
+}
+
+// read runs fn for each selected member of the archive.
+func (cmd *command) read(fn func(*ar.ReaderAt, *ar.Header) error) {
+	file, arReader, ok := cmd.open()
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	for _, header := range cmd.selected(arReader) {
+		err := fn(arReader, header)
+		if err != nil {
+			cmd.fail(exitFailure, "%s: %v", header.Name, err)
+			return
+		}
+	}
+}
+
+// list prints the member name, with its details if verbose.
+func (cmd *command) list(arReader *ar.ReaderAt, header *ar.Header) error {
+	if !cmd.opts.verbose {
+		_, err := fmt.Fprintln(cmd.stdout, header.Name)
+		return err
+	}
+
+	mode := os.FileMode(header.Mode).Perm().String()
+	_, err := fmt.Fprintf(cmd.stdout, "%s %d/%d %6d %s %s\n", mode[1:], header.Uid, header.Gid,
+		header.Size, header.ModTime.Format("Jan _2 15:04 2006"), header.Name)
+	return err
+}
+
+// print writes the member contents to stdout.
+func (cmd *command) print(arReader *ar.ReaderAt, header *ar.Header) error {
+	if cmd.opts.verbose {
+		fmt.Fprintf(cmd.stdout, "\n<%s>\n\n", header.Name)
+	}
+
+	contents := arReader.Section(header)
+	if contents == nil {
+		return ar.ErrThinMember
+	}
+
+	_, err := io.Copy(cmd.stdout, contents)
+	return err
+}
+
+// extract writes the member contents to a file in the working directory.
+func (cmd *command) extract(arReader *ar.ReaderAt, header *ar.Header) error {
+	if cmd.opts.verbose {
+		fmt.Fprintf(cmd.stdout, "x - %s\n", header.Name)
+	}
+
+	contents := arReader.Section(header)
+	if contents == nil {
+		return ar.ErrThinMember
+	}
+
+	mode := os.FileMode(header.Mode).Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+
+	out, err := os.OpenFile(filepath.Base(header.Name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, contents)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	err = out.Close()
+	if err != nil || !cmd.opts.preserve {
+		return err
+	}
+
+	return os.Chtimes(out.Name(), header.ModTime, header.ModTime)
+}
+
+// edit loads the archive, changes it with fn, and saves it.
+func (cmd *command) edit(fn func(*ar.Archive) error) {
+	file, _, ok := cmd.open()
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		cmd.fail(exitFailure, "%s: %v", cmd.archive, err)
+		return
+	}
+
+	archive, err := ar.OpenArchivePath(file, stat.Size(), cmd.archive)
+	if err != nil {
+		cmd.fail(exitFailure, "%s: file format not recognized", cmd.archive)
+		return
+	}
+
+	cmd.save(archive, fn)
+}
+
+// write loads the archive, creating it if it doesn't exist, changes it with
+// fn, and saves it.
+func (cmd *command) write(fn func(*ar.Archive) error) {
+	_, err := os.Stat(cmd.archive)
+	if err == nil {
+		cmd.edit(fn)
+		return
+	}
+	if !os.IsNotExist(err) {
+		cmd.fail(exitFailure, "%s: %v", cmd.archive, err)
+		return
+	}
+
+	if !cmd.opts.create {
+		fmt.Fprintf(cmd.stderr, "ar: creating %s\n", cmd.archive)
+	}
+
+	cmd.save(ar.NewArchive(ar.FormatGNU), fn)
+}
+
+// save changes archive with fn and atomically replaces the archive file.
+func (cmd *command) save(archive *ar.Archive, fn func(*ar.Archive) error) {
+	archive.Deterministic = cmd.opts.deterministic
+
+	err := fn(archive)
+	if err != nil {
+		if cmd.status == exitOK {
+			cmd.status = exitFailure
+		}
+		return
+	}
+
+	out, err := ioutil.TempFile(filepath.Dir(cmd.archive), "ar")
+	if err != nil {
+		cmd.fail(exitFailure, "%v", err)
+		return
+	}
+	defer os.Remove(out.Name())
+
+	err = archive.Save(out)
+	if err == nil {
+		err = out.Close()
+	} else {
+		out.Close()
+	}
+	if err == nil {
+		err = os.Chmod(out.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), cmd.archive)
+	}
+	if err != nil {
+		cmd.fail(exitFailure, "%s: %v", cmd.archive, err)
+	}
+}
+
+// delete removes the named members.
+func (cmd *command) delete(archive *ar.Archive) error {
+	for _, name := range cmd.members {
+		// Like GNU ar, missing members are only reported when verbose.
+		err := archive.Delete(name)
+		if err != nil {
+			if cmd.opts.verbose {
+				fmt.Fprintf(cmd.stdout, "No member named `%s'\n", name)
+			}
+			continue
+		}
+
+		if cmd.opts.verbose {
+			fmt.Fprintf(cmd.stdout, "d - %s\n", name)
+		}
+	}
+
+	return nil
+}
+
+// move moves the named members to the requested position.
+func (cmd *command) move(archive *ar.Archive) error {
+	members := make(map[string]bool)
+	for _, header := range archive.Members() {
+		members[header.Name] = true
+	}
+
+	for _, name := range cmd.members {
+		if !members[name] {
+			cmd.fail(exitFailure, "no entry %s in archive %s!", name, cmd.archive)
+			return ar.ErrMemberNotFound
+		}
+	}
+
+	pos := cmd.position(archive)
+	err := archive.Move(cmd.members, pos)
+	if err != nil {
+		cmd.fail(exitFailure, "%s: %v", cmd.archive, err)
+		return err
+	}
+
+	if cmd.opts.verbose {
+		for _, name := range cmd.members {
+			fmt.Fprintf(cmd.stdout, "m - %s\n", name)
+		}
+	}
+
+	return nil
+}
+
+// replace replaces or adds the named files.
+func (cmd *command) replace(archive *ar.Archive) error {
+	existing := make(map[string]bool)
+	for _, header := range archive.Members() {
+		existing[header.Name] = true
+	}
+	pos := cmd.position(archive)
+
+	return cmd.addFiles(func(header *ar.Header, open ar.Opener) error {
+		if cmd.opts.update {
+			updated, err := archive.UpdateOpener(header, open, pos)
+			if err != nil || !updated {
+				return err
+			}
+		} else {
+			err := archive.ReplaceOpener(header, open, pos)
+			if err != nil {
+				return err
+			}
+		}
+
+		if cmd.opts.verbose {
+			action := "a"
+			if existing[header.Name] {
+				action = "r"
+			}
+
+			fmt.Fprintf(cmd.stdout, "%s - %s\n", action, header.Name)
+		}
+
+		return nil
+	})
+}
+
+// quickAppend appends the named files without checking for existing members.
+func (cmd *command) quickAppend(archive *ar.Archive) error {
+	return cmd.addFiles(func(header *ar.Header, open ar.Opener) error {
+		archive.AppendOpener(header, open)
+
+		if cmd.opts.verbose {
+			fmt.Fprintf(cmd.stdout, "a - %s\n", header.Name)
+		}
+
+		return nil
+	})
+}
+
+// addFiles stats each named file and calls fn with its header and an opener
+// for its contents. The files are only opened while the archive is saved.
+func (cmd *command) addFiles(fn func(*ar.Header, ar.Opener) error) error {
+	for _, name := range cmd.members {
+		stat, err := os.Stat(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				cmd.fail(exitFailure, "%s: No such file or directory", name)
+			} else {
+				cmd.fail(exitFailure, "%s: %v", name, err)
+			}
+
+			return err
+		}
+
+		header := ar.FileInfoHeader(stat)
+		header.ModTime = header.ModTime.Truncate(time.Second)
+
+		err = fn(header, ar.FileOpener(name))
+		if err != nil {
+			cmd.fail(exitFailure, "%s: %v", name, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// position returns the requested position, falling back to the end of the
+// archive if relpos doesn't exist like GNU ar.
+func (cmd *command) position(archive *ar.Archive) ar.Position {
+	if !cmd.opts.positioned {
+		return ar.Position{}
+	}
+
+	for _, header := range archive.Members() {
+		if header.Name == cmd.opts.pos.Name {
+			return cmd.opts.pos
+		}
+	}
+
+	return ar.Position{}
+}
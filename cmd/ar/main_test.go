@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/larzconwell/ar"
+)
+
+func runAr(t *testing.T, status int, args ...string) string {
+	var stdout, stderr bytes.Buffer
+
+	code := run(args, &stdout, &stderr)
+	if code != status {
+		t.Fatalf("ar %v exited with %d, want %d: %s", args, code, status, stderr.String())
+	}
+
+	return stdout.String()
+}
+
+func TestCommands(t *testing.T) {
+	time.Local = time.UTC
+
+	object, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	err = os.Chdir(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{"exit.o": object, "notes.txt": []byte("notes\n")}
+	for name, contents := range files {
+		err = ioutil.WriteFile(name, contents, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if out := runAr(t, 0, "rcv", "lib.a", "exit.o", "notes.txt"); out != "a - exit.o\na - notes.txt\n" {
+		t.Errorf("Unexpected r output %q.", out)
+	}
+
+	if out := runAr(t, 0, "rv", "lib.a", "exit.o"); out != "r - exit.o\n" {
+		t.Errorf("Unexpected r output %q.", out)
+	}
+
+	if out := runAr(t, 0, "tv", "lib.a"); out != "rw-r--r-- 0/0    560 Jan  1 00:00 1970 exit.o\n"+
+		"rw-r--r-- 0/0      6 Jan  1 00:00 1970 notes.txt\n" {
+		t.Errorf("Unexpected tv output %q.", out)
+	}
+
+	runAr(t, 0, "mb", "exit.o", "lib.a", "notes.txt")
+	if out := runAr(t, 0, "t", "lib.a"); out != "notes.txt\nexit.o\n" {
+		t.Errorf("Unexpected order after m %q.", out)
+	}
+
+	if out := runAr(t, 0, "p", "lib.a", "notes.txt"); out != "notes\n" {
+		t.Errorf("Unexpected p output %q.", out)
+	}
+
+	err = os.Remove("exit.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if out := runAr(t, 0, "xv", "lib.a", "exit.o"); out != "x - exit.o\n" {
+		t.Errorf("Unexpected x output %q.", out)
+	}
+
+	extracted, err := ioutil.ReadFile("exit.o")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(extracted, object) {
+		t.Error("Extracted file doesn't match the original.")
+	}
+
+	if out := runAr(t, 0, "dv", "lib.a", "notes.txt"); out != "d - notes.txt\n" {
+		t.Errorf("Unexpected d output %q.", out)
+	}
+
+	if out := runAr(t, 0, "t", "lib.a"); out != "exit.o\n" {
+		t.Errorf("Unexpected contents after d %q.", out)
+	}
+
+	if out := runAr(t, 0, "d", "lib.a", "missing.o"); out != "" {
+		t.Errorf("Unexpected d output for a missing member %q.", out)
+	}
+	if out := runAr(t, 0, "dv", "lib.a", "missing.o"); out != "No member named `missing.o'\n" {
+		t.Errorf("Unexpected dv output for a missing member %q.", out)
+	}
+
+	runAr(t, 1, "m", "lib.a", "missing.o")
+	runAr(t, 1, "z", "lib.a")
+	runAr(t, 9, "t", "missing.a")
+}
+
+func TestThinIndex(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"thin_test.a", "exit.o", "hello.o"} {
+		contents, err := ioutil.ReadFile(filepath.Join("..", "..", "testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, name), contents, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The index is regenerated from the files the archive references.
+	archive := filepath.Join(dir, "thin_test.a")
+	runAr(t, 0, "s", archive)
+
+	in, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader, err := ar.OpenReaderAt(in, stat.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !arReader.Thin() {
+		t.Error("The archive should still be thin.")
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 4 {
+		t.Error("The archive should be indexed after s.")
+	}
+}
@@ -0,0 +1,427 @@
+package ar
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"sort"
+)
+
+var (
+	ErrImportObject = errors.New("ar: invalid import object")
+	ErrCOFFTooLarge = errors.New("ar: too many members or offsets too large for COFF")
+
+	errBigobj = errors.New("ar: invalid COFF bigobj object")
+)
+
+// importObjectMagic identifies short import objects, the unknown machine type
+// followed by 0xFFFF. Anonymous objects share it, using a nonzero version.
+var importObjectMagic = []byte{0x00, 0x00, 0xff, 0xff}
+
+// bigobjClassID identifies the anonymous objects /bigobj creates, from
+// ANON_OBJECT_HEADER_BIGOBJ in winnt.h.
+var bigobjClassID = []byte{
+	0xc7, 0xa1, 0xba, 0xd1, 0xee, 0xba, 0xa9, 0x4b,
+	0xaf, 0x20, 0xfa, 0xf6, 0x6a, 0xa4, 0xdc, 0xb8,
+}
+
+// ltcgClassID identifies the anonymous objects /GL creates, which hold the
+// compiler's intermediate code instead of a symbol table. From ClGlObjMagic
+// in LLVM's COFF.h.
+var ltcgClassID = []byte{
+	0x38, 0xfe, 0xb3, 0x0c, 0xa5, 0xd9, 0xab, 0x4d,
+	0xac, 0x9b, 0xd6, 0xb6, 0x22, 0x26, 0x53, 0xc2,
+}
+
+// coffMagics identifies COFF objects by their target machine, since they have
+// no magic number, and short import objects.
+var coffMagics = [][]byte{
+	{0x4c, 0x01}, {0x64, 0x86}, {0xc4, 0x01}, {0x64, 0xaa}, importObjectMagic,
+}
+
+// COFF symbol storage classes, from the PE format specification.
+const (
+	coffClassExternal     = 2
+	coffClassWeakExternal = 105
+)
+
+// Layout of /bigobj objects, which widen the section numbers of symbols.
+const (
+	bigobjVersion    = 2
+	bigobjHeaderSize = 56
+	bigobjSymbolSize = 20
+)
+
+// ImportType is the kind of symbol an ImportObject imports.
+type ImportType uint8
+
+const (
+	ImportCode ImportType = iota
+	ImportData
+	ImportConst
+)
+
+// ImportNameType is how the name a symbol is imported by is derived from the
+// ImportObject symbol name.
+type ImportNameType uint8
+
+const (
+	ImportOrdinal ImportNameType = iota
+	ImportName
+	ImportNameNoPrefix
+	ImportNameUndecorate
+	ImportNameExportAs
+)
+
+// ImportObject is a short import object, the IMPORT_OBJECT_HEADER and names
+// Windows import libraries store for each symbol exported by a DLL in place of
+// a full COFF object.
+type ImportObject struct {
+	Version       uint16
+	Machine       uint16 // Target machine, one of the debug/pe IMAGE_FILE_MACHINE values.
+	TimeDateStamp uint32
+	OrdinalOrHint uint16
+	Type          ImportType
+	NameType      ImportNameType
+	Symbol        string // Name of the imported symbol.
+	DLL           string // Name of the DLL exporting the symbol.
+	ExportAs      string // Name the symbol is exported as, for ImportNameExportAs.
+}
+
+// ParseImportObject parses a short import object from the file entry contents
+// read from r. ErrImportObject is returned if it isn't an import object, like
+// anonymous objects which have a nonzero version.
+func ParseImportObject(r io.Reader) (*ImportObject, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) < 20 || !bytes.HasPrefix(contents, importObjectMagic) {
+		return nil, ErrImportObject
+	}
+	if binary.LittleEndian.Uint16(contents[4:]) != 0 {
+		return nil, ErrImportObject
+	}
+
+	typeInfo := binary.LittleEndian.Uint16(contents[18:])
+	object := &ImportObject{
+		Version:       binary.LittleEndian.Uint16(contents[4:]),
+		Machine:       binary.LittleEndian.Uint16(contents[6:]),
+		TimeDateStamp: binary.LittleEndian.Uint32(contents[8:]),
+		OrdinalOrHint: binary.LittleEndian.Uint16(contents[16:]),
+		Type:          ImportType(typeInfo & 0x3),
+		NameType:      ImportNameType((typeInfo >> 2) & 0x7),
+	}
+
+	size := int64(binary.LittleEndian.Uint32(contents[12:]))
+	if size != int64(len(contents)-20) {
+		return nil, ErrImportObject
+	}
+
+	names := bytes.Split(contents[20:], []byte{0})
+	if len(names) < 3 || len(names[len(names)-1]) != 0 {
+		return nil, ErrImportObject
+	}
+	object.Symbol = string(names[0])
+	object.DLL = string(names[1])
+	if object.NameType == ImportNameExportAs && len(names) > 3 {
+		object.ExportAs = string(names[2])
+	}
+
+	return object, nil
+}
+
+// MarshalBinary encodes the import object as the contents of a file entry.
+func (imp *ImportObject) MarshalBinary() ([]byte, error) {
+	names := imp.Symbol + "\u0000" + imp.DLL + "\u0000"
+	if imp.NameType == ImportNameExportAs {
+		names += imp.ExportAs + "\u0000"
+	}
+
+	contents := make([]byte, 20, 20+len(names))
+	copy(contents, importObjectMagic)
+	binary.LittleEndian.PutUint16(contents[4:], imp.Version)
+	binary.LittleEndian.PutUint16(contents[6:], imp.Machine)
+	binary.LittleEndian.PutUint32(contents[8:], imp.TimeDateStamp)
+	binary.LittleEndian.PutUint32(contents[12:], uint32(len(names)))
+	binary.LittleEndian.PutUint16(contents[16:], imp.OrdinalOrHint)
+	binary.LittleEndian.PutUint16(contents[18:], uint16(imp.Type&0x3)|uint16(imp.NameType&0x7)<<2)
+
+	return append(contents, names...), nil
+}
+
+// coffSymbols returns the names of the external symbols defined by the COFF
+// object read from r, the same symbols lib.exe adds to the linker members.
+// Import objects define the __imp_ symbol, and the symbol itself unless they
+// import data. The anonymous objects /GL creates return ErrUnsupportedObject,
+// other anonymous objects besides /bigobj objects are invalid.
+func coffSymbols(r io.ReaderAt) ([]string, error) {
+	start := make([]byte, 6)
+	_, err := r.ReadAt(start, 0)
+	if err == nil && bytes.HasPrefix(start, importObjectMagic) && binary.LittleEndian.Uint16(start[4:]) != 0 {
+		return bigobjSymbols(r)
+	}
+
+	imp, err := ParseImportObject(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err == nil {
+		names := []string{"__imp_" + imp.Symbol}
+		if imp.Type != ImportData {
+			names = append(names, imp.Symbol)
+		}
+
+		return names, nil
+	}
+	if err != ErrImportObject {
+		return nil, err
+	}
+
+	file, err := pe.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	names := make([]string, 0)
+	for _, sym := range file.Symbols {
+		if coffExternal(sym.StorageClass, int32(sym.SectionNumber), sym.Value) && sym.Name != "" {
+			names = append(names, sym.Name)
+		}
+	}
+
+	return names, nil
+}
+
+// bigobjSymbols returns the names of the external symbols defined by the
+// /bigobj object read from r. ErrUnsupportedObject is returned for /GL
+// objects, and errBigobj for other anonymous objects or data like them.
+func bigobjSymbols(r io.ReaderAt) ([]string, error) {
+	header := make([]byte, bigobjHeaderSize)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// Both headers store the class ID after the version, machine and time.
+	version := binary.LittleEndian.Uint16(header[4:])
+	if bytes.Equal(header[12:28], ltcgClassID) {
+		return nil, ErrUnsupportedObject
+	}
+	if n < bigobjHeaderSize || version != bigobjVersion || !bytes.Equal(header[12:28], bigobjClassID) {
+		return nil, errBigobj
+	}
+
+	// The strings table follows the symbols, starting with its size.
+	offset := int64(binary.LittleEndian.Uint32(header[48:]))
+	count := int64(binary.LittleEndian.Uint32(header[52:]))
+	symbols, err := ioutil.ReadAll(io.NewSectionReader(r, offset, count*bigobjSymbolSize+4))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(symbols)) != count*bigobjSymbolSize+4 {
+		return nil, errBigobj
+	}
+
+	size := int64(binary.LittleEndian.Uint32(symbols[count*bigobjSymbolSize:]))
+	table, err := ioutil.ReadAll(io.NewSectionReader(r, offset+count*bigobjSymbolSize, size))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for i := int64(0); i < count; i++ {
+		sym := symbols[i*bigobjSymbolSize:]
+		value := binary.LittleEndian.Uint32(sym[8:])
+		section := int32(binary.LittleEndian.Uint32(sym[12:]))
+		class := sym[18]
+		i += int64(sym[19]) // Skip the auxiliary symbols.
+
+		if !coffExternal(class, section, value) {
+			continue
+		}
+
+		// Long names are stored as an offset into the strings table.
+		name := sym[:8]
+		if binary.LittleEndian.Uint32(name) == 0 {
+			start := int64(binary.LittleEndian.Uint32(name[4:]))
+			if start >= int64(len(table)) {
+				return nil, errBigobj
+			}
+
+			name = table[start:]
+		}
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+
+	return names, nil
+}
+
+// coffExternal checks if a symbol with the storage class, section number and
+// value is an external definition.
+func coffExternal(class uint8, section int32, value uint32) bool {
+	switch class {
+	case coffClassExternal:
+		// Undefined symbols with a value are common symbols.
+		return section != 0 || value != 0
+	case coffClassWeakExternal:
+		return true
+	}
+
+	return false
+}
+
+// parseCOFFSymbolTable parses the COFF second linker member, which maps the
+// sorted symbols to 1-based indexes into a table of member offsets.
+func parseCOFFSymbolTable(table []byte) (*SymbolTable, error) {
+	if len(table) < 4 {
+		return nil, ErrSymbolTable
+	}
+	members := int64(binary.LittleEndian.Uint32(table))
+	table = table[4:]
+	if members*4+4 > int64(len(table)) {
+		return nil, ErrSymbolTable
+	}
+	offsets := table[:members*4]
+	table = table[members*4:]
+
+	count := int64(binary.LittleEndian.Uint32(table))
+	table = table[4:]
+	if count*2 > int64(len(table)) {
+		return nil, ErrSymbolTable
+	}
+	indexes := table[:count*2]
+	names := bytes.Split(table[count*2:], []byte{0})
+	if int64(len(names)) <= count {
+		return nil, ErrSymbolTable
+	}
+
+	symbols := newSymbolTable()
+	for i := int64(0); i < count; i++ {
+		index := int64(binary.LittleEndian.Uint16(indexes[i*2:]))
+		if index < 1 || index > members {
+			return nil, ErrSymbolTable
+		}
+
+		symbols.add(string(names[i]), int64(binary.LittleEndian.Uint32(offsets[(index-1)*4:])))
+	}
+
+	return symbols, nil
+}
+
+// writeCOFFTables writes the magic number, the first linker member in the
+// GNU symbol table layout, the second linker member with the member offsets
+// and sorted symbols, and the longnames member.
+func (arw *Writer) writeCOFFTables() error {
+	var err error
+
+	symbols := make([]*entry, len(arw.symbols))
+	copy(symbols, arw.symbols)
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].Name < symbols[j].Name
+	})
+
+	index := make(map[int64]int, len(arw.members))
+	for i, offset := range arw.members {
+		index[offset] = i + 1
+	}
+
+	var names bytes.Buffer
+	for _, entry := range symbols {
+		names.WriteString(entry.Name + "\u0000")
+	}
+
+	// Calculate the size of the data before file entries, used to complete
+	// the member offsets. There's no 64 bit variant of the linker members.
+	firstSize := 4 + 4*int64(len(symbols)) + int64(names.Len())
+	secondSize := 4 + 4*int64(len(arw.members)) + 4 + 2*int64(len(symbols)) + int64(names.Len())
+	size := 8 + 60 + firstSize + firstSize%2 + 60 + secondSize + secondSize%2
+	if arw.strings.Len() > 0 {
+		size += 60 + int64(arw.strings.Len()+arw.strings.Len()%2)
+	}
+	// The member indexes are 16 bits.
+	if len(arw.members) > math.MaxUint16 {
+		return ErrCOFFTooLarge
+	}
+	if len(arw.members) > 0 && arw.members[len(arw.members)-1]+size > math.MaxUint32 {
+		return ErrCOFFTooLarge
+	}
+
+	// Create the first linker member, in archive order.
+	var first bytes.Buffer
+	putUint(binary.BigEndian, &first, 4, int64(len(arw.symbols)))
+	for _, entry := range arw.symbols {
+		putUint(binary.BigEndian, &first, 4, entry.Offset+size)
+	}
+	for _, entry := range arw.symbols {
+		first.WriteString(entry.Name + "\u0000")
+	}
+
+	// Create the second linker member, sorted by name.
+	var second bytes.Buffer
+	putUint(binary.LittleEndian, &second, 4, int64(len(arw.members)))
+	for _, offset := range arw.members {
+		putUint(binary.LittleEndian, &second, 4, offset+size)
+	}
+	putUint(binary.LittleEndian, &second, 4, int64(len(symbols)))
+	for _, entry := range symbols {
+		binary.Write(&second, binary.LittleEndian, uint16(index[entry.Offset]))
+	}
+	names.WriteTo(&second)
+
+	_, err = arw.writer.Write([]byte("!<arch>\n"))
+	if err != nil {
+		return err
+	}
+
+	err = arw.writeTable("/", &first)
+	if err != nil {
+		return err
+	}
+	err = arw.writeTable("/", &second)
+	if err != nil {
+		return err
+	}
+
+	if arw.strings.Len() > 0 {
+		err = arw.writeTable("//", arw.strings)
+	}
+
+	return err
+}
+
+// writeTable writes a table entry named name with the contents of table,
+// adding the padding byte if needed.
+func (arw *Writer) writeTable(name string, table *bytes.Buffer) error {
+	hdr, err := arw.createHeader(false, &Header{
+		Name:    name,
+		ModTime: arw.tableTime(),
+		Uid:     0,
+		Gid:     0,
+		Mode:    0,
+		Size:    int64(table.Len()),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = arw.writer.Write(hdr)
+	if err != nil {
+		return err
+	}
+
+	if table.Len()%2 != 0 {
+		table.WriteByte('\n')
+	}
+	_, err = table.WriteTo(arw.writer)
+
+	return err
+}
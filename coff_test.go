@@ -0,0 +1,356 @@
+package ar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCOFFWrite(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatCOFF})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exit, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hello, err := ioutil.ReadFile(filepath.Join("testdata", "hello.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imp, err := (&ImportObject{Machine: 0x8664, Symbol: "Hello", DLL: "hello.dll", NameType: ImportName}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"exit.o", "a_rather_long_object_name.o", "hello.dll"}
+	files := map[string][]byte{names[0]: exit, names[1]: hello, names[2]: imp}
+	for _, name := range names {
+		err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := out.Bytes()
+	if !bytes.Contains(archive, []byte("\n//")) || !bytes.Contains(archive, []byte("a_rather_long_object_name.o\x00")) {
+		t.Error("Long names should be null terminated in the longnames member.")
+	}
+
+	arReader := NewReader(bytes.NewReader(archive))
+	for _, name := range names {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil || header.Name != name {
+			t.Fatal("Reader should find the written entries, skipping both linker members.")
+		}
+
+		contents, err := ioutil.ReadAll(arReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(contents, files[name]) {
+			t.Error("Entry contents aren't what they should be.")
+		}
+	}
+
+	if arReader.Format() != FormatCOFF {
+		t.Error("Reader should detect the COFF format.")
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 6 {
+		t.Fatal("Symbol table should contain the symbols from the objects and import object.")
+	}
+	for i, name := range []string{"Hello", "__imp_Hello", "exit", "hello", "maybe", "world"} {
+		if symbols.Symbols[i].Name != name {
+			t.Error("Symbols should be sorted by name.")
+		}
+	}
+	if symbols.Lookup("exit").Member != "exit.o" || symbols.Lookup("world").Member != "a_rather_long_object_name.o" {
+		t.Error("Symbols should resolve to their defining entries.")
+	}
+	if symbols.Lookup("__imp_Hello").Member != "hello.dll" {
+		t.Error("Import object symbols should resolve to the import object.")
+	}
+}
+
+func TestCOFFSymbols(t *testing.T) {
+	for _, name := range []string{"llvm_lib.lib", "llvm_dlltool.lib"} {
+		path := filepath.Join("testdata", "corpus", name)
+		golden, err := ioutil.ReadFile(path + ".golden")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer in.Close()
+		arReader := NewReader(in)
+
+		// The linker members list the symbols in member order, like the
+		// symbols are returned for each member.
+		listing := ""
+		for {
+			header, err := arReader.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header == nil {
+				break
+			}
+
+			contents, err := ioutil.ReadAll(arReader)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names, err := coffSymbols(bytes.NewReader(contents))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, sym := range names {
+				listing += "symbol " + sym + " " + header.Name + "\n"
+			}
+		}
+
+		expected := string(golden[bytes.Index(golden, []byte("symbol ")):])
+		if listing != expected {
+			t.Errorf("Symbols for %s are:\n%s\nexpected:\n%s", name, listing, expected)
+		}
+	}
+}
+
+func TestBigobjSymbols(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "coff_bigobj.obj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	// The symbols llvm-lib adds to the linker members for the object.
+	names, err := coffSymbols(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "hello weakfn .weak.weakfn.default.hello counter common_buf a_very_long_symbol_name_for_the_string_table"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("Symbols are %q, expected %q.", names, expected)
+	}
+}
+
+func TestUnsupportedObject(t *testing.T) {
+	// An anonymous object with the LTCG class ID /GL uses.
+	ltcg := []byte("\x00\x00\xff\xff\x01\x00\x64\x86\x00\x00\x00\x00" +
+		"\x38\xfe\xb3\x0c\xa5\xd9\xab\x4d\xac\x9b\xd6\xb6\x22\x26\x53\xc2" +
+		"\x00\x00\x00\x00")
+
+	for _, last := range []bool{false, true} {
+		arWriter, err := NewWriterOptions(ioutil.Discard, WriterOptions{Format: FormatCOFF})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = arWriter.WriteHeader(&Header{Name: "ltcg.obj", Mode: 0644, Size: int64(len(ltcg))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(ltcg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The error is returned once the entry is complete.
+		if last {
+			err = arWriter.Close()
+		} else {
+			err = arWriter.WriteHeader(&Header{Name: "README", Mode: 0644})
+		}
+
+		var serr *SymbolError
+		if !errors.As(err, &serr) || serr.Name != "ltcg.obj" || !errors.Is(err, ErrUnsupportedObject) {
+			t.Errorf("Completing the entry should return a SymbolError, got %v.", err)
+		}
+	}
+
+	// Only link.exe reads the object, other formats leave it out.
+	for _, format := range []Format{FormatGNU, FormatCommon, FormatBSD} {
+		arWriter, err := NewWriterOptions(ioutil.Discard, WriterOptions{Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = arWriter.WriteHeader(&Header{Name: "ltcg.obj", Mode: 0644, Size: int64(len(ltcg))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(ltcg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = arWriter.Close()
+		if err != nil {
+			t.Errorf("Format %d shouldn't reject the object, got %v.", format, err)
+		}
+	}
+}
+
+func TestAnonymousData(t *testing.T) {
+	// Data starting like an anonymous object is an opaque member.
+	data := []byte("\x00\x00\xff\xff\x01\x00data blob.....")
+
+	_, err := coffSymbols(bytes.NewReader(data))
+	if err != errBigobj {
+		t.Errorf("Data should be an invalid anonymous object, got %v.", err)
+	}
+
+	for _, format := range []Format{FormatGNU, FormatCommon, FormatCOFF} {
+		arWriter, err := NewWriterOptions(ioutil.Discard, WriterOptions{Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = arWriter.WriteHeader(&Header{Name: "blob", Mode: 0644, Size: int64(len(data))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = arWriter.Close()
+		if err != nil {
+			t.Errorf("Format %d should write data members, got %v.", format, err)
+		}
+	}
+}
+
+func TestCOFFBlankFields(t *testing.T) {
+	archive := "!<arch>\n" +
+		fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", "/", "1700000000", "", "", "", "4") + "\x00\x00\x00\x00" +
+		fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", "a.obj/", "1700000000", "", "", "100666", "2") + "ok"
+
+	header, err := NewReader(bytes.NewReader([]byte(archive))).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Name != "a.obj" || header.Uid != 0 || header.Gid != 0 || header.Size != 2 {
+		t.Error("Blank uid and gid fields should be read as zero.")
+	}
+
+	// Blank fields are recorded outside the linker members when lenient.
+	arReader := NewReaderOptions(bytes.NewReader([]byte(archive)), ReaderOptions{Lenient: true})
+	_, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warnings := arReader.Warnings()
+	if len(warnings) != 2 || warnings[0].Field != "uid" || warnings[1].Field != "gid" || warnings[0].Index != 1 {
+		t.Errorf("Blank fields should be recorded as warnings, got %v.", warnings)
+	}
+}
+
+func TestImportObject(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "corpus", "llvm_dlltool.lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	arReader := NewReader(in)
+
+	objects := make([]*ImportObject, 0)
+	for {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil {
+			break
+		}
+
+		contents, err := ioutil.ReadAll(arReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		object, err := ParseImportObject(bytes.NewReader(contents))
+		if err == ErrImportObject {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		marshaled, err := object.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(marshaled, contents) {
+			t.Error("Marshaled import object should match the original contents.")
+		}
+
+		objects = append(objects, object)
+	}
+
+	if len(objects) != 3 {
+		t.Fatal("Library should contain three import objects.")
+	}
+
+	expected := []ImportObject{
+		{Machine: 0x8664, Type: ImportCode, NameType: ImportName, Symbol: "Hello", DLL: "hello.dll"},
+		{Machine: 0x8664, Type: ImportData, NameType: ImportName, Symbol: "Data", DLL: "hello.dll"},
+		{Machine: 0x8664, Type: ImportCode, NameType: ImportOrdinal, Symbol: "ByOrd", DLL: "hello.dll", OrdinalOrHint: 5},
+	}
+	for i, object := range objects {
+		if *object != expected[i] {
+			t.Errorf("Import object %d is %+v, expected %+v.", i, *object, expected[i])
+		}
+	}
+}
+
+func TestInvalidImportObject(t *testing.T) {
+	invalid := [][]byte{
+		[]byte("\x7fELF"),
+		[]byte("\x00\x00\xff\xff\x00\x00\x64\x86\x00\x00\x00\x00\x03\x00\x00\x00\x00\x00\x04\x00a\x00b"),
+		[]byte("\x00\x00\xff\xff\x00\x00\x64\x86\x00\x00\x00\x00\x09\x00\x00\x00\x00\x00\x04\x00a\x00b\x00"),
+		// Anonymous objects have a nonzero version.
+		[]byte("\x00\x00\xff\xff\x01\x00\x64\x86\x00\x00\x00\x00\x04\x00\x00\x00\x00\x00\x04\x00a\x00b\x00"),
+	}
+
+	for _, contents := range invalid {
+		_, err := ParseImportObject(bytes.NewReader(contents))
+		if err != ErrImportObject {
+			t.Errorf("Parsing %q should return ErrImportObject.", contents)
+		}
+	}
+}
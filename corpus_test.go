@@ -0,0 +1,102 @@
+package ar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The corpus in testdata/corpus contains archives created by GNU ar, llvm-ar
+// in its GNU and BSD formats, llvm-libtool-darwin, llvm-lib, llvm-dlltool, go
+// tool pack, and bsdtar --format=arbsd, which uses the libarchive writer
+// FreeBSD's ar is built on. The golden listings are written by golden.sh from
+// llvm-ar tv and llvm-nm --print-armap, rewritten into the listing format of
+// listArchive, so they aren't derived from this package.
+
+// listArchive formats the members and symbols of an archive like the golden
+// listings.
+func listArchive(t *testing.T, r io.Reader) string {
+	var listing bytes.Buffer
+	arReader := NewReaderOptions(r, ReaderOptions{GoMetadata: true})
+
+	for {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil {
+			break
+		}
+
+		n, err := io.Copy(ioutil.Discard, arReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !arReader.Thin() && n != header.Size {
+			t.Errorf("Entry %s contents should be %d bytes, read %d.", header.Name, header.Size, n)
+		}
+
+		listing.WriteString(listHeader(header))
+	}
+
+	if symbols := arReader.Symbols(); symbols != nil {
+		for _, sym := range symbols.Symbols {
+			fmt.Fprintf(&listing, "symbol %s %s\n", sym.Name, sym.Member)
+		}
+	}
+
+	return listing.String()
+}
+
+// listHeader formats a header like ar tv.
+func listHeader(header *Header) string {
+	return fmt.Sprintf("%s %d/%d %d %s %s\n", os.FileMode(header.Mode).Perm().String()[1:],
+		header.Uid, header.Gid, header.Size, header.ModTime.UTC().Format("2006-01-02T15:04"), header.Name)
+}
+
+func TestCorpus(t *testing.T) {
+	goldens, err := filepath.Glob(filepath.Join("testdata", "corpus", "*.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goldens) == 0 {
+		t.Fatal("Corpus should contain golden listings.")
+	}
+
+	for _, golden := range goldens {
+		t.Run(filepath.Base(golden), func(t *testing.T) {
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			archive, err := ioutil.ReadFile(strings.TrimSuffix(golden, ".golden"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			listing := listArchive(t, bytes.NewReader(archive))
+			if listing != string(expected) {
+				t.Errorf("Listing doesn't match the golden listing:\n%s\nexpected:\n%s", listing, expected)
+			}
+
+			ara, err := OpenReaderAtOptions(bytes.NewReader(archive), int64(len(archive)), ReaderOptions{GoMetadata: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			members := ""
+			for _, header := range ara.Members() {
+				members += listHeader(header)
+			}
+			if !strings.HasPrefix(listing, members) || strings.HasPrefix(listing[len(members):], "rw") {
+				t.Error("ReaderAt should index the same members.")
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+// Package deb implements reading and writing Debian binary packages.
+//
+// A package is an ar archive in the common format containing the
+// debian-binary version file, followed by the control.tar and data.tar
+// tarballs, which may be compressed.
+//
+// Uncompressed, gzip, bzip2, xz and zstd tarballs are read by default, xz
+// and zstd being what dpkg-deb creates by default. Other compressions need a
+// Decompressor registered with RegisterDecompressor, otherwise reading them
+// returns ErrCompression.
+//
+// References:
+//
+//	https://manpages.debian.org/deb.5
+package deb
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/larzconwell/ar"
+	"github.com/larzconwell/ar/deb/internal/xz"
+	"github.com/larzconwell/ar/deb/internal/zstd"
+)
+
+var (
+	ErrFormat      = errors.New("deb: invalid package format")
+	ErrVersion     = errors.New("deb: unsupported package version")
+	ErrCompression = errors.New("deb: unsupported tarball compression")
+	ErrOrder       = errors.New("deb: tarballs accessed out of order")
+)
+
+// Version is the package format version written by Writer.
+const Version = "2.0"
+
+// Decompressor returns a reader decompressing the contents of r.
+type Decompressor func(r io.Reader) (io.Reader, error)
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = map[string]Decompressor{
+		"": func(r io.Reader) (io.Reader, error) {
+			return r, nil
+		},
+		".gz": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		".bz2": func(r io.Reader) (io.Reader, error) {
+			return bzip2.NewReader(r), nil
+		},
+		".xz": func(r io.Reader) (io.Reader, error) {
+			return xz.NewReader(r)
+		},
+		".zst": func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		},
+	}
+)
+
+// RegisterDecompressor registers a Decompressor for tarballs with the name
+// extension ext, e.g. ".lzma". Uncompressed, gzip, bzip2, xz and zstd
+// tarballs are supported by default, registering one of their extensions
+// replaces the built in Decompressor.
+func RegisterDecompressor(ext string, decompressor Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+
+	decompressors[ext] = decompressor
+}
+
+// decompressor returns the Decompressor for ext.
+func decompressor(ext string) (Decompressor, bool) {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+
+	decompressor, ok := decompressors[ext]
+	return decompressor, ok
+}
+
+// Reader provides sequential access to the tarballs in a Debian package.
+// Control must be read before Data, since both are read from the same stream.
+type Reader struct {
+	Version string // Package format version from debian-binary.
+	arr     *ar.Reader
+	header  *ar.Header // Header of the current member.
+	control bool       // If the control tarball has been accessed.
+	data    bool       // If the data tarball has been accessed.
+}
+
+// NewReader creates a Reader reading the package from r, validating the
+// debian-binary member. ErrVersion is returned if the format version isn't
+// supported.
+func NewReader(r io.Reader) (*Reader, error) {
+	dr := &Reader{arr: ar.NewReader(r)}
+
+	header, err := dr.next()
+	if err != nil {
+		return nil, err
+	}
+	if header.Name != "debian-binary" {
+		return nil, ErrFormat
+	}
+
+	contents, err := ioutil.ReadAll(io.LimitReader(dr.arr, 64))
+	if err != nil {
+		return nil, err
+	}
+
+	dr.Version = strings.TrimSpace(string(contents))
+	if !strings.HasPrefix(dr.Version, "2.") {
+		return nil, ErrVersion
+	}
+
+	dr.header, err = dr.next()
+	if err != nil {
+		return nil, err
+	}
+
+	return dr, nil
+}
+
+// Control returns a reader for the control tarball, decompressing it if
+// needed. ErrOrder is returned if Control or Data has already been called,
+// and ErrCompression if no Decompressor is registered for its extension.
+func (dr *Reader) Control() (*tar.Reader, error) {
+	if dr.control || dr.data {
+		return nil, ErrOrder
+	}
+	dr.control = true
+
+	return dr.tarball("control.tar")
+}
+
+// Data returns a reader for the data tarball, decompressing it if needed.
+// The rest of the control tarball is skipped. ErrOrder is returned if Data
+// has already been called, and ErrCompression if no Decompressor is
+// registered for its extension.
+func (dr *Reader) Data() (*tar.Reader, error) {
+	if dr.data {
+		return nil, ErrOrder
+	}
+	dr.data = true
+
+	if !dr.control {
+		dr.control = true
+		if !strings.HasPrefix(dr.header.Name, "control.tar") {
+			return nil, ErrFormat
+		}
+	}
+
+	header, err := dr.next()
+	if err != nil {
+		return nil, err
+	}
+	dr.header = header
+
+	return dr.tarball("data.tar")
+}
+
+// tarball returns a reader for the current member, which must be named
+// prefix with an optional compression extension.
+func (dr *Reader) tarball(prefix string) (*tar.Reader, error) {
+	if !strings.HasPrefix(dr.header.Name, prefix) {
+		return nil, ErrFormat
+	}
+
+	decompress, ok := decompressor(dr.header.Name[len(prefix):])
+	if !ok {
+		return nil, ErrCompression
+	}
+
+	contents, err := decompress(dr.arr)
+	if err != nil {
+		return nil, err
+	}
+
+	return tar.NewReader(contents), nil
+}
+
+// next returns the next member, skipping members reserved for additions
+// whose names start with _. ErrFormat is returned if there are no members.
+func (dr *Reader) next() (*ar.Header, error) {
+	for {
+		header, err := dr.arr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			return nil, ErrFormat
+		}
+
+		if !strings.HasPrefix(header.Name, "_") {
+			return header, nil
+		}
+	}
+}
+
+// Writer builds a Debian package with dpkg compatible member headers. The
+// debian-binary member is written when the first tarball is written.
+type Writer struct {
+	ModTime time.Time // Modification time for the members, defaults to now.
+	arw     *ar.Writer
+	control bool // If the control tarball has been written.
+	data    bool // If the data tarball has been written.
+}
+
+// NewWriter creates a Writer writing the package to w.
+func NewWriter(w io.Writer) *Writer {
+	arw, _ := ar.NewWriterOptions(w, ar.WriterOptions{Format: ar.FormatCommon})
+
+	return &Writer{ModTime: time.Now(), arw: arw}
+}
+
+// WriteControl writes the control tarball of size bytes read from r. ext is
+// the compression extension of the tarball, e.g. ".gz", or empty if it's
+// uncompressed. ErrOrder is returned if it's already been written.
+func (dw *Writer) WriteControl(ext string, size int64, r io.Reader) error {
+	if dw.control {
+		return ErrOrder
+	}
+	dw.control = true
+
+	err := dw.writeMember("debian-binary", int64(len(Version)+1), strings.NewReader(Version+"\n"))
+	if err != nil {
+		return err
+	}
+
+	return dw.writeMember("control.tar"+ext, size, r)
+}
+
+// WriteData writes the data tarball of size bytes read from r, see
+// WriteControl. ErrOrder is returned if the control tarball hasn't been
+// written, or the data tarball already has.
+func (dw *Writer) WriteData(ext string, size int64, r io.Reader) error {
+	if !dw.control || dw.data {
+		return ErrOrder
+	}
+	dw.data = true
+
+	return dw.writeMember("data.tar"+ext, size, r)
+}
+
+// Close writes the package. ErrOrder is returned if either tarball hasn't
+// been written.
+func (dw *Writer) Close() error {
+	if !dw.control || !dw.data {
+		return ErrOrder
+	}
+
+	return dw.arw.Close()
+}
+
+// writeMember writes a member with the headers dpkg-deb uses.
+func (dw *Writer) writeMember(name string, size int64, r io.Reader) error {
+	err := dw.arw.WriteHeader(&ar.Header{
+		Name:    name,
+		ModTime: dw.ModTime,
+		Mode:    0644,
+		Size:    size,
+	})
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(dw.arw, r)
+	if err == nil && n != size {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,198 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readFile(t *testing.T, tr *tar.Reader, name string) []byte {
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			t.Fatalf("Tarball is missing %s: %v", name, err)
+		}
+
+		if header.Name == name {
+			contents, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			return contents
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	// dpkg-deb builds of the same package with gzip, xz and zstd tarballs.
+	for _, name := range []string{"hello.deb", "hello_xz.deb", "hello_zstd.deb"} {
+		t.Run(name, func(t *testing.T) {
+			in, err := os.Open(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer in.Close()
+
+			pkg, err := NewReader(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if pkg.Version != "2.0" {
+				t.Error("Package version should be 2.0.")
+			}
+
+			control, err := pkg.Control()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(readFile(t, control, "./control"), []byte("Package: hello\n")) {
+				t.Error("Control file doesn't contain the package name.")
+			}
+
+			_, err = pkg.Control()
+			if err != ErrOrder {
+				t.Error("Control should return ErrOrder when read twice.")
+			}
+
+			data, err := pkg.Data()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(readFile(t, data, "./usr/share/hello/greeting")) != "hello world\n" {
+				t.Error("Data file contents aren't what they should be.")
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var control bytes.Buffer
+	gz := gzip.NewWriter(&control)
+	tw := tar.NewWriter(gz)
+	controlFile := []byte("Package: hello\n")
+	err := tw.WriteHeader(&tar.Header{Name: "./control", Mode: 0644, Size: int64(len(controlFile))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw.Write(controlFile)
+	tw.Close()
+	gz.Close()
+
+	var data bytes.Buffer
+	tw = tar.NewWriter(&data)
+	tw.Close()
+
+	var out bytes.Buffer
+	pkg := NewWriter(&out)
+	pkg.ModTime = time.Unix(1700000000, 0)
+
+	err = pkg.WriteData("", int64(data.Len()), bytes.NewReader(data.Bytes()))
+	if err != ErrOrder {
+		t.Error("WriteData should return ErrOrder before the control tarball.")
+	}
+
+	err = pkg.WriteControl(".gz", int64(control.Len()), &control)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pkg.WriteData("", int64(data.Len()), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = pkg.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The debian-binary member should match dpkg-deb's output.
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "hello.deb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes()[:72], expected[:72]) {
+		t.Error("debian-binary member doesn't match dpkg-deb.")
+	}
+
+	written, err := NewReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, err := written.Control()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readFile(t, tr, "./control"), controlFile) {
+		t.Error("Control file doesn't match the written file.")
+	}
+
+	_, err = written.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompression(t *testing.T) {
+	t.Cleanup(func() {
+		decompressorsMu.Lock()
+		delete(decompressors, ".lzma")
+		decompressorsMu.Unlock()
+	})
+
+	var data bytes.Buffer
+	tw := tar.NewWriter(&data)
+	tw.Close()
+
+	// The tarball isn't compressed, the registered Decompressor passes it
+	// through.
+	var out bytes.Buffer
+	pkg := NewWriter(&out)
+	err := pkg.WriteControl("", int64(data.Len()), bytes.NewReader(data.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pkg.WriteData(".lzma", int64(data.Len()), bytes.NewReader(data.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = pkg.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := NewReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = written.Data()
+	if err != ErrCompression {
+		t.Error("Data should return ErrCompression for lzma tarballs by default.")
+	}
+
+	RegisterDecompressor(".lzma", func(r io.Reader) (io.Reader, error) {
+		return r, nil
+	})
+
+	written, err = NewReader(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := written.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tr.Next()
+	if err != io.EOF {
+		t.Error("Data should use the registered Decompressor.")
+	}
+}
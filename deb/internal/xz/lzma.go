@@ -0,0 +1,380 @@
+package xz
+
+import (
+	"encoding/binary"
+)
+
+// LZMA model sizes, from the LZMA specification.
+const (
+	lzmaStates        = 12
+	lzmaPosBitsMax    = 4
+	lzmaLenToPosState = 4
+	lzmaPosSlotBits   = 6
+	lzmaAlignBits     = 4
+	lzmaEndPosModel   = 14
+	lzmaFullDistances = 1 << (lzmaEndPosModel >> 1)
+	lzmaLiteralSize   = 0x300
+	lzmaMatchMinLen   = 2
+)
+
+// Range coder constants.
+const (
+	probBits     = 11
+	probInit     = 1 << (probBits - 1)
+	probMoveBits = 5
+	rangeTop     = 1 << 24
+)
+
+// prob is the probability of a bit being 0, scaled to probBits.
+type prob uint16
+
+// rangeDecoder decodes bits from the compressed data of an LZMA chunk.
+type rangeDecoder struct {
+	buf   []byte
+	pos   int
+	rng   uint32
+	code  uint32
+	short bool // If more bytes were needed than buf has.
+}
+
+// newRangeDecoder creates a rangeDecoder for buf, which starts with a zero
+// byte and the initial code.
+func newRangeDecoder(buf []byte) (*rangeDecoder, error) {
+	if len(buf) < 5 || buf[0] != 0 {
+		return nil, ErrData
+	}
+
+	return &rangeDecoder{
+		buf:  buf,
+		pos:  5,
+		rng:  0xffffffff,
+		code: binary.BigEndian.Uint32(buf[1:]),
+	}, nil
+}
+
+// normalize shifts in the next byte once the range is too small.
+func (rc *rangeDecoder) normalize() {
+	if rc.rng >= rangeTop {
+		return
+	}
+
+	var b byte
+	if rc.pos < len(rc.buf) {
+		b = rc.buf[rc.pos]
+		rc.pos++
+	} else {
+		rc.short = true
+	}
+
+	rc.rng <<= 8
+	rc.code = rc.code<<8 | uint32(b)
+}
+
+// finished checks if the chunk's data was decoded exactly.
+func (rc *rangeDecoder) finished() bool {
+	rc.normalize()
+	return !rc.short && rc.pos == len(rc.buf) && rc.code == 0
+}
+
+// bit decodes a bit with the probability p, adapting it.
+func (rc *rangeDecoder) bit(p *prob) uint32 {
+	rc.normalize()
+
+	bound := (rc.rng >> probBits) * uint32(*p)
+	if rc.code < bound {
+		rc.rng = bound
+		*p += ((1 << probBits) - *p) >> probMoveBits
+		return 0
+	}
+
+	rc.rng -= bound
+	rc.code -= bound
+	*p -= *p >> probMoveBits
+	return 1
+}
+
+// direct decodes n bits with fixed probabilities, most significant first.
+func (rc *rangeDecoder) direct(n uint32) uint32 {
+	var v uint32
+	for ; n > 0; n-- {
+		rc.normalize()
+
+		rc.rng >>= 1
+		var b uint32
+		if rc.code >= rc.rng {
+			rc.code -= rc.rng
+			b = 1
+		}
+		v = v<<1 | b
+	}
+
+	return v
+}
+
+// bitTree decodes n bits, most significant first, using the binary tree of
+// probabilities probs.
+func (rc *rangeDecoder) bitTree(probs []prob, n uint32) uint32 {
+	m := uint32(1)
+	for i := uint32(0); i < n; i++ {
+		m = m<<1 | rc.bit(&probs[m])
+	}
+
+	return m - 1<<n
+}
+
+// reverseBitTree decodes n bits, least significant first, using the binary
+// tree of probabilities probs.
+func (rc *rangeDecoder) reverseBitTree(probs []prob, n uint32) uint32 {
+	m := uint32(1)
+	var v uint32
+	for i := uint32(0); i < n; i++ {
+		b := rc.bit(&probs[m])
+		m = m<<1 | b
+		v |= b << i
+	}
+
+	return v
+}
+
+// lengthDecoder decodes match lengths.
+type lengthDecoder struct {
+	choice  prob
+	choice2 prob
+	low     [1 << lzmaPosBitsMax][1 << 3]prob
+	mid     [1 << lzmaPosBitsMax][1 << 3]prob
+	high    [1 << 8]prob
+}
+
+// reset resets the probabilities.
+func (ld *lengthDecoder) reset() {
+	ld.choice = probInit
+	ld.choice2 = probInit
+	for i := range ld.low {
+		resetProbs(ld.low[i][:])
+		resetProbs(ld.mid[i][:])
+	}
+	resetProbs(ld.high[:])
+}
+
+// decode decodes a match length for posState.
+func (ld *lengthDecoder) decode(rc *rangeDecoder, posState uint32) int {
+	if rc.bit(&ld.choice) == 0 {
+		return lzmaMatchMinLen + int(rc.bitTree(ld.low[posState][:], 3))
+	}
+	if rc.bit(&ld.choice2) == 0 {
+		return lzmaMatchMinLen + 8 + int(rc.bitTree(ld.mid[posState][:], 3))
+	}
+
+	return lzmaMatchMinLen + 16 + int(rc.bitTree(ld.high[:], 8))
+}
+
+// lzmaDecoder is the state of the LZMA decoder, kept between the chunks of
+// an LZMA2 block unless they reset it.
+type lzmaDecoder struct {
+	lc, lp, pb uint32
+	state      uint32
+	rep        [4]uint32 // Distances of the last matches, minus one.
+
+	isMatch    [lzmaStates << lzmaPosBitsMax]prob
+	isRep      [lzmaStates]prob
+	isRepG0    [lzmaStates]prob
+	isRepG1    [lzmaStates]prob
+	isRepG2    [lzmaStates]prob
+	isRep0Long [lzmaStates << lzmaPosBitsMax]prob
+	literal    []prob
+	posSlot    [lzmaLenToPosState][1 << lzmaPosSlotBits]prob
+	posSpecial [1 + lzmaFullDistances - lzmaEndPosModel]prob
+	align      [1 << lzmaAlignBits]prob
+	matchLen   lengthDecoder
+	repLen     lengthDecoder
+}
+
+// setProps sets the literal context, literal position and position bits from
+// the properties byte, and resets the state.
+func (ld *lzmaDecoder) setProps(props byte) error {
+	if props >= 9*5*5 {
+		return ErrData
+	}
+
+	lc := uint32(props % 9)
+	lp := uint32(props / 9 % 5)
+	pb := uint32(props / 45)
+	if lc+lp > 4 {
+		return ErrData
+	}
+
+	ld.lc, ld.lp, ld.pb = lc, lp, pb
+	ld.literal = make([]prob, lzmaLiteralSize<<(lc+lp))
+	ld.reset()
+
+	return nil
+}
+
+// reset resets the state and probabilities.
+func (ld *lzmaDecoder) reset() {
+	ld.state = 0
+	ld.rep = [4]uint32{}
+
+	resetProbs(ld.isMatch[:])
+	resetProbs(ld.isRep[:])
+	resetProbs(ld.isRepG0[:])
+	resetProbs(ld.isRepG1[:])
+	resetProbs(ld.isRepG2[:])
+	resetProbs(ld.isRep0Long[:])
+	resetProbs(ld.literal)
+	for i := range ld.posSlot {
+		resetProbs(ld.posSlot[i][:])
+	}
+	resetProbs(ld.posSpecial[:])
+	resetProbs(ld.align[:])
+	ld.matchLen.reset()
+	ld.repLen.reset()
+}
+
+// decode decodes n bytes into the window.
+func (ld *lzmaDecoder) decode(rc *rangeDecoder, w *window, n int) error {
+	end := w.total + int64(n)
+	for w.total < end {
+		posState := uint32(w.total) & (1<<ld.pb - 1)
+		state2 := ld.state<<lzmaPosBitsMax | posState
+
+		if rc.bit(&ld.isMatch[state2]) == 0 {
+			err := ld.decodeLiteral(rc, w)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		var length int
+		if rc.bit(&ld.isRep[ld.state]) == 0 {
+			length = ld.matchLen.decode(rc, posState)
+			ld.rep[3], ld.rep[2], ld.rep[1] = ld.rep[2], ld.rep[1], ld.rep[0]
+			ld.rep[0] = ld.decodeDistance(rc, length)
+			ld.state = nextState(ld.state, 7, 10)
+		} else if rc.bit(&ld.isRepG0[ld.state]) == 0 {
+			// A single byte from the last match distance is a short rep.
+			if rc.bit(&ld.isRep0Long[state2]) == 0 {
+				length = 1
+				ld.state = nextState(ld.state, 9, 11)
+			}
+		} else {
+			var dist uint32
+			if rc.bit(&ld.isRepG1[ld.state]) == 0 {
+				dist = ld.rep[1]
+			} else {
+				if rc.bit(&ld.isRepG2[ld.state]) == 0 {
+					dist = ld.rep[2]
+				} else {
+					dist = ld.rep[3]
+					ld.rep[3] = ld.rep[2]
+				}
+				ld.rep[2] = ld.rep[1]
+			}
+			ld.rep[1] = ld.rep[0]
+			ld.rep[0] = dist
+		}
+
+		if length == 0 {
+			length = ld.repLen.decode(rc, posState)
+			ld.state = nextState(ld.state, 8, 11)
+		}
+
+		// Matches can't end past the chunk, or start before the window.
+		dist := int64(ld.rep[0]) + 1
+		if !w.has(dist) || int64(length) > end-w.total {
+			return ErrData
+		}
+		w.repeat(int(dist), length)
+	}
+
+	return nil
+}
+
+// decodeLiteral decodes a literal byte into the window.
+func (ld *lzmaDecoder) decodeLiteral(rc *rangeDecoder, w *window) error {
+	var prev uint32
+	if w.total > 0 {
+		prev = uint32(w.get(1))
+	}
+
+	i := (uint32(w.total)&(1<<ld.lp-1))<<ld.lc + prev>>(8-ld.lc)
+	probs := ld.literal[lzmaLiteralSize*i : lzmaLiteralSize*(i+1)]
+
+	// After a match, the byte at the match distance predicts the literal
+	// until a bit differs.
+	symbol := uint32(1)
+	if ld.state >= 7 {
+		dist := int64(ld.rep[0]) + 1
+		if !w.has(dist) {
+			return ErrData
+		}
+
+		match := uint32(w.get(int(dist)))
+		for symbol < 0x100 {
+			matchBit := match >> 7 & 1
+			match <<= 1
+
+			bit := rc.bit(&probs[(1+matchBit)<<8+symbol])
+			symbol = symbol<<1 | bit
+			if bit != matchBit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = symbol<<1 | rc.bit(&probs[symbol])
+	}
+
+	w.put(byte(symbol))
+	switch {
+	case ld.state < 4:
+		ld.state = 0
+	case ld.state < 10:
+		ld.state -= 3
+	default:
+		ld.state -= 6
+	}
+
+	return nil
+}
+
+// decodeDistance decodes the distance of a match of length, minus one.
+func (ld *lzmaDecoder) decodeDistance(rc *rangeDecoder, length int) uint32 {
+	lenState := length - lzmaMatchMinLen
+	if lenState >= lzmaLenToPosState {
+		lenState = lzmaLenToPosState - 1
+	}
+
+	slot := rc.bitTree(ld.posSlot[lenState][:], lzmaPosSlotBits)
+	if slot < 4 {
+		return slot
+	}
+
+	bits := slot>>1 - 1
+	dist := (2 | slot&1) << bits
+	if slot < lzmaEndPosModel {
+		return dist + rc.reverseBitTree(ld.posSpecial[dist-slot:], bits)
+	}
+
+	dist += rc.direct(bits-lzmaAlignBits) << lzmaAlignBits
+	return dist + rc.reverseBitTree(ld.align[:], lzmaAlignBits)
+}
+
+// nextState returns the state after a match, lit if the previous state was a
+// literal and otherwise match.
+func nextState(state, lit, match uint32) uint32 {
+	if state < 7 {
+		return lit
+	}
+
+	return match
+}
+
+// resetProbs sets probs to the initial probability.
+func resetProbs(probs []prob) {
+	for i := range probs {
+		probs[i] = probInit
+	}
+}
@@ -0,0 +1,217 @@
+package xz
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// byteReader is the source of the compressed data.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// lzma2Block decompresses the LZMA2 chunks of a block.
+type lzma2Block struct {
+	src           byteReader
+	dict          window
+	lzma          lzmaDecoder
+	needDictReset bool
+	needProps     bool
+	buf           []byte // Compressed data of the current chunk.
+
+	headerSize   int64 // Size of the block header.
+	compressed   int64 // Compressed size from the header, or -1.
+	size         int64 // Uncompressed size from the header, or -1.
+	uncompressed int64 // Bytes decompressed.
+}
+
+// newLZMA2Block creates an lzma2Block reading from src, props is the LZMA2
+// filter properties byte.
+func newLZMA2Block(src byteReader, props byte) (*lzma2Block, error) {
+	if props > 40 {
+		return nil, ErrFormat
+	}
+
+	size := uint64(0xffffffff)
+	if props < 40 {
+		size = uint64(2|props&1) << (props/2 + 11)
+	}
+
+	return &lzma2Block{
+		src:           src,
+		dict:          window{size: int64(size)},
+		needDictReset: true,
+		needProps:     true,
+	}, nil
+}
+
+// chunk decompresses the next chunk, returning its data which is valid until
+// the next call. io.EOF is returned at the end of the block.
+func (lb *lzma2Block) chunk() ([]byte, error) {
+	control, err := lb.src.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if control == 0x00 {
+		return nil, io.EOF
+	}
+
+	// Dictionary resets also need the LZMA properties to be reset.
+	if control >= 0xe0 || control == 0x01 {
+		lb.needProps = true
+		lb.needDictReset = false
+		lb.dict.reset()
+	} else if lb.needDictReset {
+		return nil, ErrData
+	}
+	lb.dict.out = lb.dict.out[:0]
+
+	// Uncompressed chunks.
+	if control < 0x80 {
+		if control > 0x02 {
+			return nil, ErrData
+		}
+
+		size, err := lb.readSize()
+		if err != nil {
+			return nil, err
+		}
+		err = lb.readCompressed(size)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, b := range lb.buf {
+			lb.dict.put(b)
+		}
+
+		return lb.dict.out, nil
+	}
+
+	size, err := lb.readSize()
+	if err != nil {
+		return nil, err
+	}
+	size += int(control&0x1f) << 16
+	compressed, err := lb.readSize()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case control >= 0xc0:
+		props, err := lb.src.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+
+		err = lb.lzma.setProps(props)
+		if err != nil {
+			return nil, err
+		}
+		lb.needProps = false
+	case lb.needProps:
+		return nil, ErrData
+	case control >= 0xa0:
+		lb.lzma.reset()
+	}
+
+	err = lb.readCompressed(compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := newRangeDecoder(lb.buf)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lb.lzma.decode(rc, &lb.dict, size)
+	if err != nil {
+		return nil, err
+	}
+	if !rc.finished() {
+		return nil, ErrData
+	}
+
+	return lb.dict.out, nil
+}
+
+// readSize reads a big endian size field, stored minus one.
+func (lb *lzma2Block) readSize() (int, error) {
+	var size [2]byte
+	_, err := io.ReadFull(lb.src, size[:])
+	if err != nil {
+		return 0, unexpected(err)
+	}
+
+	return int(binary.BigEndian.Uint16(size[:])) + 1, nil
+}
+
+// readCompressed reads the size bytes of the chunk's data into buf.
+func (lb *lzma2Block) readCompressed(size int) error {
+	if cap(lb.buf) < size {
+		lb.buf = make([]byte, size)
+	}
+	lb.buf = lb.buf[:size]
+
+	_, err := io.ReadFull(lb.src, lb.buf)
+	return unexpected(err)
+}
+
+// window is the LZMA dictionary, the most recent decompressed data which
+// matches are copied from.
+type window struct {
+	buf   []byte // Grows up to size bytes, then wraps.
+	size  int64
+	pos   int    // Position in buf of the next byte.
+	total int64  // Bytes written since the window was reset.
+	out   []byte // Bytes written for the current chunk.
+}
+
+// reset empties the window.
+func (w *window) reset() {
+	w.buf = w.buf[:0]
+	w.pos = 0
+	w.total = 0
+}
+
+// put writes b to the window.
+func (w *window) put(b byte) {
+	if int64(len(w.buf)) < w.size {
+		w.buf = append(w.buf, b)
+	} else {
+		w.buf[w.pos] = b
+	}
+
+	w.pos++
+	if int64(w.pos) == w.size {
+		w.pos = 0
+	}
+	w.total++
+	w.out = append(w.out, b)
+}
+
+// has checks if the byte dist bytes back is in the window.
+func (w *window) has(dist int64) bool {
+	return dist > 0 && dist <= w.total && dist <= w.size
+}
+
+// get returns the byte dist bytes back, which must be in the window.
+func (w *window) get(dist int) byte {
+	i := w.pos - dist
+	if i < 0 {
+		i += len(w.buf)
+	}
+
+	return w.buf[i]
+}
+
+// repeat copies n bytes starting dist bytes back, which must be in the
+// window.
+func (w *window) repeat(dist, n int) {
+	for ; n > 0; n-- {
+		w.put(w.get(dist))
+	}
+}
@@ -0,0 +1,452 @@
+// Package xz implements reading of xz compressed data, the format dpkg-deb
+// compresses tarballs with by default. Only the LZMA2 filter is supported,
+// the only filter xz uses unless it's told otherwise.
+//
+// References:
+//
+//	https://tukaani.org/xz/xz-file-format.txt
+//	https://www.7-zip.org/a/lzma-specification.7z
+package xz
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+var (
+	ErrFormat   = errors.New("xz: invalid format")
+	ErrData     = errors.New("xz: invalid compressed data")
+	ErrChecksum = errors.New("xz: checksum mismatch")
+	ErrFilter   = errors.New("xz: unsupported filter")
+)
+
+// Magic numbers of the stream header and footer.
+var (
+	headerMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	footerMagic = []byte{'Y', 'Z'}
+)
+
+// Check types verified by the Reader, other checks are skipped.
+const (
+	checkNone   = 0x00
+	checkCRC32  = 0x01
+	checkCRC64  = 0x04
+	checkSHA256 = 0x0a
+)
+
+// checkSizes are the sizes of the checks by their ID.
+var checkSizes = [16]int{0, 4, 4, 4, 8, 8, 8, 16, 16, 16, 32, 32, 32, 64, 64, 64}
+
+// lzma2Filter is the filter ID of LZMA2.
+const lzma2Filter = 0x21
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// record is the index record for a block.
+type record struct {
+	unpadded     int64 // Size of the block without its padding.
+	uncompressed int64
+}
+
+// Reader decompresses xz data read from an underlying reader. Concatenated
+// streams are read as one, like xz does.
+type Reader struct {
+	src     *countReader
+	flags   []byte      // Stream flags from the stream header.
+	check   hash.Hash   // Check of the current block, nil if it's not verified.
+	block   *lzma2Block // The current block, nil between blocks.
+	records []record    // Records of the blocks read from the current stream.
+	out     []byte      // Decompressed data that hasn't been read.
+	err     error
+}
+
+// NewReader creates a Reader decompressing r, reading the stream header.
+// ErrFormat is returned if r doesn't start with one.
+func NewReader(r io.Reader) (*Reader, error) {
+	z := &Reader{src: &countReader{r: bufio.NewReader(r)}}
+
+	err := z.readStreamHeader()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return z, nil
+}
+
+// Read reads decompressed data into p. At the end of the data the index and
+// checks have been verified, and io.EOF is returned.
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+
+		z.err = z.next()
+	}
+
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+
+	return n, nil
+}
+
+// next decompresses the next LZMA2 chunk, reading the block headers, index
+// and streams around them.
+func (z *Reader) next() error {
+	if z.block == nil {
+		return z.readBlockHeader()
+	}
+
+	out, err := z.block.chunk()
+	if err == io.EOF {
+		return z.finishBlock()
+	}
+	if err != nil {
+		return unexpected(err)
+	}
+
+	z.block.uncompressed += int64(len(out))
+	if z.block.size >= 0 && z.block.uncompressed > z.block.size {
+		return ErrData
+	}
+	if z.check != nil {
+		z.check.Write(out)
+	}
+
+	z.out = out
+	return nil
+}
+
+// readStreamHeader reads the stream header, returning io.EOF if there's no
+// data.
+func (z *Reader) readStreamHeader() error {
+	header := make([]byte, 12)
+	n, err := io.ReadFull(z.src, header)
+	if n == 0 && err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return unexpected(err)
+	}
+
+	if !bytes.Equal(header[:6], headerMagic) {
+		return ErrFormat
+	}
+	if header[6] != 0 || header[7] > 0x0f {
+		return ErrFormat
+	}
+	if crc32.ChecksumIEEE(header[6:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return ErrChecksum
+	}
+
+	z.flags = header[6:8]
+	z.records = z.records[:0]
+	return nil
+}
+
+// readBlockHeader reads the next block header, or the index if there are no
+// more blocks in the stream.
+func (z *Reader) readBlockHeader() error {
+	z.src.n = 0
+	z.src.hash = crc32.NewIEEE()
+	sizeByte, err := z.src.ReadByte()
+	if err != nil {
+		return unexpected(err)
+	}
+	if sizeByte == 0 {
+		return z.readIndex()
+	}
+
+	size := (int(sizeByte) + 1) * 4
+	header := make([]byte, size)
+	header[0] = sizeByte
+	_, err = io.ReadFull(z.src, header[1:])
+	if err != nil {
+		return unexpected(err)
+	}
+	z.src.hash = nil
+
+	if crc32.ChecksumIEEE(header[:size-4]) != binary.LittleEndian.Uint32(header[size-4:]) {
+		return ErrChecksum
+	}
+
+	// The reserved flags must be unset.
+	flags := header[1]
+	if flags&0x3c != 0 {
+		return ErrFormat
+	}
+
+	fields := bytes.NewReader(header[2 : size-4])
+	compressed, uncompressed := int64(-1), int64(-1)
+	if flags&0x40 != 0 {
+		compressed, err = readVarint(fields)
+		if err != nil || compressed == 0 {
+			return ErrFormat
+		}
+	}
+	if flags&0x80 != 0 {
+		uncompressed, err = readVarint(fields)
+		if err != nil {
+			return ErrFormat
+		}
+	}
+
+	// Only a single LZMA2 filter is supported.
+	if flags&0x03 != 0 {
+		return ErrFilter
+	}
+	id, err := readVarint(fields)
+	if err != nil {
+		return ErrFormat
+	}
+	propsSize, err := readVarint(fields)
+	if err != nil {
+		return ErrFormat
+	}
+	if id != lzma2Filter {
+		return ErrFilter
+	}
+	if propsSize != 1 {
+		return ErrFormat
+	}
+	props, err := fields.ReadByte()
+	if err != nil {
+		return ErrFormat
+	}
+
+	// The rest of the header is padding.
+	for fields.Len() > 0 {
+		b, _ := fields.ReadByte()
+		if b != 0 {
+			return ErrFormat
+		}
+	}
+
+	block, err := newLZMA2Block(z.src, props)
+	if err != nil {
+		return err
+	}
+	block.headerSize = int64(size)
+	block.compressed = compressed
+	block.size = uncompressed
+	z.block = block
+
+	z.src.n = 0
+	z.check = newCheck(z.flags[1])
+	return nil
+}
+
+// finishBlock verifies the sizes, padding and check of the current block.
+func (z *Reader) finishBlock() error {
+	block := z.block
+	z.block = nil
+
+	compressed := z.src.n
+	if block.compressed >= 0 && compressed != block.compressed {
+		return ErrData
+	}
+	if block.size >= 0 && block.uncompressed != block.size {
+		return ErrData
+	}
+
+	// The block is padded to a multiple of four bytes.
+	for i := (block.headerSize + compressed) % 4; i%4 != 0; i++ {
+		b, err := z.src.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+		if b != 0 {
+			return ErrFormat
+		}
+	}
+
+	id := z.flags[1]
+	sum := make([]byte, checkSizes[id])
+	_, err := io.ReadFull(z.src, sum)
+	if err != nil {
+		return unexpected(err)
+	}
+	if z.check != nil {
+		expected := z.check.Sum(nil)
+		if id != checkSHA256 {
+			// CRC checks are stored little endian.
+			for i, j := 0, len(expected)-1; i < j; i, j = i+1, j-1 {
+				expected[i], expected[j] = expected[j], expected[i]
+			}
+		}
+
+		if !bytes.Equal(sum, expected) {
+			return ErrChecksum
+		}
+	}
+
+	z.records = append(z.records, record{
+		unpadded:     block.headerSize + compressed + int64(len(sum)),
+		uncompressed: block.uncompressed,
+	})
+	return nil
+}
+
+// readIndex reads the index, whose indicator has been read, and the stream
+// footer, verifying them against the blocks in the stream. The next stream
+// header is read if there is one.
+func (z *Reader) readIndex() error {
+	count, err := readVarint(z.src)
+	if err != nil {
+		return unexpected(err)
+	}
+	if count != int64(len(z.records)) {
+		return ErrData
+	}
+
+	for _, rec := range z.records {
+		unpadded, err := readVarint(z.src)
+		if err != nil {
+			return unexpected(err)
+		}
+		uncompressed, err := readVarint(z.src)
+		if err != nil {
+			return unexpected(err)
+		}
+
+		if unpadded != rec.unpadded || uncompressed != rec.uncompressed {
+			return ErrData
+		}
+	}
+
+	for z.src.n%4 != 0 {
+		b, err := z.src.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+		if b != 0 {
+			return ErrFormat
+		}
+	}
+
+	size := z.src.n + 4
+	crc := z.src.hash.(hash.Hash32).Sum32()
+	z.src.hash = nil
+
+	footer := make([]byte, 16)
+	_, err = io.ReadFull(z.src, footer)
+	if err != nil {
+		return unexpected(err)
+	}
+	if binary.LittleEndian.Uint32(footer) != crc {
+		return ErrChecksum
+	}
+	if crc32.ChecksumIEEE(footer[8:14]) != binary.LittleEndian.Uint32(footer[4:]) {
+		return ErrChecksum
+	}
+	backward := (int64(binary.LittleEndian.Uint32(footer[8:])) + 1) * 4
+	if backward != size || !bytes.Equal(footer[12:14], z.flags) || !bytes.Equal(footer[14:], footerMagic) {
+		return ErrFormat
+	}
+
+	// Streams may be followed by padding in multiples of four bytes, then
+	// another stream.
+	for {
+		padding, err := z.src.r.Peek(4)
+		if len(padding) == 0 && err == io.EOF {
+			return io.EOF
+		}
+		if !bytes.Equal(padding, []byte{0, 0, 0, 0}) {
+			break
+		}
+
+		z.src.r.Discard(4)
+	}
+
+	return z.readStreamHeader()
+}
+
+// newCheck returns the hash for the check type id, or nil if it's not
+// verified.
+func newCheck(id byte) hash.Hash {
+	switch id {
+	case checkCRC32:
+		return crc32.NewIEEE()
+	case checkCRC64:
+		return crc64.New(crc64Table)
+	case checkSHA256:
+		return sha256.New()
+	}
+
+	return nil
+}
+
+// readVarint reads a variable length integer, seven bits per byte starting
+// with the least significant.
+func readVarint(r io.ByteReader) (int64, error) {
+	var v int64
+	for i := uint(0); i < 9; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		v |= int64(b&0x7f) << (i * 7)
+		if b&0x80 == 0 {
+			// The encoding must be the shortest.
+			if b == 0 && i > 0 {
+				return 0, ErrFormat
+			}
+
+			return v, nil
+		}
+	}
+
+	return 0, ErrFormat
+}
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF, the data ends early.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// countReader counts the bytes read from r, and optionally adds them to a
+// hash.
+type countReader struct {
+	r    *bufio.Reader
+	n    int64
+	hash hash.Hash
+}
+
+func (cr *countReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	if cr.hash != nil {
+		cr.hash.Write(p[:n])
+	}
+
+	return n, err
+}
+
+func (cr *countReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	cr.n++
+	if cr.hash != nil {
+		cr.hash.Write([]byte{b})
+	}
+
+	return b, nil
+}
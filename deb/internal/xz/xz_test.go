@@ -0,0 +1,85 @@
+package xz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func decompress(data []byte) ([]byte, error) {
+	z, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(z)
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestRead(t *testing.T) {
+	words := readTestdata(t, "words.txt")
+	expected := map[string][]byte{
+		"words.xz":        words,
+		"words_crc32.xz":  words,
+		"words_sha256.xz": words,
+		"words_none.xz":   words,
+		"words_blocks.xz": words,
+		"words_props.xz":  words,
+		"multi.xz":        append([]byte("hello\n"), words...),
+		"padded.xz":       append(append([]byte{}, words...), words...),
+		"empty.xz":        []byte{},
+	}
+
+	for name, contents := range expected {
+		out, err := decompress(readTestdata(t, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if !bytes.Equal(out, contents) {
+			t.Errorf("%s doesn't decompress to its original contents.", name)
+		}
+	}
+}
+
+func TestReadCorrupt(t *testing.T) {
+	data := readTestdata(t, "words.xz")
+
+	_, err := decompress([]byte("not xz compressed data"))
+	if err != ErrFormat {
+		t.Error("NewReader should return ErrFormat for data that isn't xz.")
+	}
+
+	_, err = decompress(data[:len(data)/2])
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Read should return io.ErrUnexpectedEOF for truncated data.")
+	}
+
+	// The CRC64 check is the 8 bytes before the index, whose size is in the
+	// footer.
+	check := append([]byte{}, data...)
+	index := len(data) - 12 - (int(binary.LittleEndian.Uint32(data[len(data)-8:]))+1)*4
+	check[index-1] ^= 0xff
+	_, err = decompress(check)
+	if err != ErrChecksum {
+		t.Error("Read should return ErrChecksum for a block that doesn't match its check.")
+	}
+
+	padded := readTestdata(t, "padded.xz")
+	_, err = decompress(append(padded[:len(data)], 0, 0, 0, 0, 0))
+	if err == nil {
+		t.Error("Stream padding should be a multiple of four bytes.")
+	}
+}
@@ -0,0 +1,255 @@
+package zstd
+
+import (
+	"math/bits"
+)
+
+// fseEntry is a state of an FSE table: its symbol, and how to find the next
+// state.
+type fseEntry struct {
+	symbol byte
+	nbBits uint8
+	base   uint16
+}
+
+// fseTable decodes FSE states, indexed by state.
+type fseTable struct {
+	entries []fseEntry
+	accLog  uint
+}
+
+// readFSETable reads the FSE table description at the start of data, for
+// symbols up to maxSymbol with an accuracy log up to maxLog. The table and
+// the description's size are returned.
+func readFSETable(data []byte, maxLog uint, maxSymbol int) (*fseTable, int, error) {
+	br := &forwardBits{data: data}
+	accLog := uint(br.read(4)) + 5
+	if accLog > maxLog {
+		return nil, 0, ErrData
+	}
+
+	// Probabilities are stored in as few bits as the remaining total allows,
+	// with -1 for symbols less probable than 1. Zero probabilities are
+	// followed by a count of repeated zeros.
+	var norm []int
+	remaining := 1<<accLog + 1
+	threshold := 1 << accLog
+	nbBits := accLog + 1
+	for remaining > 1 {
+		if len(norm) > maxSymbol {
+			return nil, 0, ErrData
+		}
+
+		if len(norm) > 0 && norm[len(norm)-1] == 0 {
+			for {
+				repeat := int(br.read(2))
+				for i := 0; i < repeat; i++ {
+					norm = append(norm, 0)
+				}
+				if repeat != 3 {
+					break
+				}
+			}
+			if len(norm) > maxSymbol {
+				return nil, 0, ErrData
+			}
+		}
+
+		max := 2*threshold - 1 - remaining
+		v := int(br.read(nbBits - 1))
+		if v >= max {
+			v |= int(br.read(1)) << (nbBits - 1)
+			if v >= threshold {
+				v -= max
+			}
+		}
+
+		count := v - 1
+		if count < 0 {
+			remaining--
+		} else {
+			remaining -= count
+		}
+		norm = append(norm, count)
+
+		for remaining < threshold && nbBits > 1 {
+			nbBits--
+			threshold >>= 1
+		}
+	}
+	if remaining != 1 || br.overrun {
+		return nil, 0, ErrData
+	}
+
+	table, err := buildFSETable(norm, accLog)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return table, int(br.pos+7) / 8, nil
+}
+
+// buildFSETable builds the table for the normalized probabilities norm.
+func buildFSETable(norm []int, accLog uint) (*fseTable, error) {
+	size := 1 << accLog
+	table := &fseTable{entries: make([]fseEntry, size), accLog: accLog}
+	next := make([]int, len(norm))
+
+	// Symbols less probable than 1 take the last states.
+	high := size - 1
+	for symbol, count := range norm {
+		if count == -1 {
+			table.entries[high].symbol = byte(symbol)
+			high--
+			next[symbol] = 1
+		} else {
+			next[symbol] = count
+		}
+	}
+
+	// The rest are spread across the table.
+	pos, step, mask := 0, size>>1+size>>3+3, size-1
+	for symbol, count := range norm {
+		for i := 0; i < count; i++ {
+			table.entries[pos].symbol = byte(symbol)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return nil, ErrData
+	}
+
+	for i := range table.entries {
+		entry := &table.entries[i]
+		n := next[entry.symbol]
+		next[entry.symbol]++
+
+		entry.nbBits = uint8(accLog + 1 - uint(bits.Len(uint(n))))
+		entry.base = uint16(n<<entry.nbBits - size)
+	}
+
+	return table, nil
+}
+
+// rleFSETable returns a table always decoding symbol.
+func rleFSETable(symbol byte) *fseTable {
+	return &fseTable{entries: []fseEntry{{symbol: symbol}}}
+}
+
+// fseState is the state of an FSE decoder.
+type fseState struct {
+	table *fseTable
+	state uint64
+}
+
+// init reads the initial state from br.
+func (fs *fseState) init(br *backwardBits, table *fseTable) {
+	fs.table = table
+	fs.state = br.read(table.accLog)
+}
+
+// symbol returns the symbol of the current state.
+func (fs *fseState) symbol() byte {
+	return fs.table.entries[fs.state].symbol
+}
+
+// update reads the next state from br.
+func (fs *fseState) update(br *backwardBits) {
+	entry := fs.table.entries[fs.state]
+	fs.state = uint64(entry.base) + br.read(uint(entry.nbBits))
+}
+
+// forwardBits reads bits from the start of data, least significant first.
+type forwardBits struct {
+	data    []byte
+	pos     uint
+	overrun bool // If bits past the end of data were read.
+}
+
+// read reads n bits, which are zero past the end of data.
+func (br *forwardBits) read(n uint) uint64 {
+	var v uint64
+	for i := uint(0); i < n; i++ {
+		if br.pos/8 >= uint(len(br.data)) {
+			br.overrun = true
+		} else {
+			v |= uint64(br.data[br.pos/8]>>(br.pos%8)&1) << i
+		}
+		br.pos++
+	}
+
+	return v
+}
+
+// backwardBits reads bits from the end of data, most significant first. The
+// last byte's highest set bit marks the start of the bits.
+type backwardBits struct {
+	data    []byte
+	off     int // Bytes of data that haven't been loaded into bits.
+	bits    uint64
+	count   uint // Bits loaded but not read.
+	overrun uint // Bits read past the start of data, which are zero.
+}
+
+// newBackwardBits creates a backwardBits for data, skipping the padding bits.
+func newBackwardBits(data []byte) (*backwardBits, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, ErrData
+	}
+
+	br := &backwardBits{data: data, off: len(data)}
+	br.consume(9 - uint(bits.Len8(data[len(data)-1])))
+
+	return br, nil
+}
+
+// fill loads as many bytes into bits as fit.
+func (br *backwardBits) fill() {
+	for br.count <= 56 && br.off > 0 {
+		br.off--
+		br.bits = br.bits<<8 | uint64(br.data[br.off])
+		br.count += 8
+	}
+}
+
+// peek returns the next n bits without reading them, n is at most 56.
+func (br *backwardBits) peek(n uint) uint64 {
+	if br.count < n {
+		br.fill()
+	}
+	if br.count >= n {
+		return br.bits >> (br.count - n) & (1<<n - 1)
+	}
+
+	return br.bits << (n - br.count) & (1<<n - 1)
+}
+
+// consume skips n bits.
+func (br *backwardBits) consume(n uint) {
+	if br.count < n {
+		br.fill()
+	}
+	if br.count >= n {
+		br.count -= n
+		return
+	}
+
+	br.overrun += n - br.count
+	br.count = 0
+}
+
+// read reads n bits, n is at most 56.
+func (br *backwardBits) read(n uint) uint64 {
+	v := br.peek(n)
+	br.consume(n)
+
+	return v
+}
+
+// finished checks if all the bits were read, and no more.
+func (br *backwardBits) finished() bool {
+	return br.off == 0 && br.count == 0 && br.overrun == 0
+}
@@ -0,0 +1,302 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Literals section types.
+const (
+	literalsRaw = iota
+	literalsRLE
+	literalsCompressed
+	literalsTreeless
+)
+
+// maxHuffmanBits is the longest Huffman code.
+const maxHuffmanBits = 11
+
+// huffmanEntry is the symbol of a Huffman code, and the code's length.
+type huffmanEntry struct {
+	symbol byte
+	nbBits uint8
+}
+
+// huffmanTable decodes Huffman codes, indexed by the next maxBits bits.
+type huffmanTable struct {
+	entries []huffmanEntry
+	maxBits uint
+}
+
+// readLiterals reads the literals section at the start of block into
+// f.literals, returning its size.
+func (f *frame) readLiterals(block []byte, blockSize int64) (int, error) {
+	if len(block) == 0 {
+		return 0, ErrData
+	}
+
+	litType := block[0] & 0x03
+	sizeFormat := block[0] >> 2 & 0x03
+
+	if litType == literalsRaw || litType == literalsRLE {
+		var size, n int
+		switch sizeFormat {
+		case 0, 2:
+			size, n = int(block[0]>>3), 1
+		case 1:
+			if len(block) < 2 {
+				return 0, ErrData
+			}
+			size, n = int(block[0]>>4)+int(block[1])<<4, 2
+		case 3:
+			if len(block) < 3 {
+				return 0, ErrData
+			}
+			size, n = int(block[0]>>4)+int(block[1])<<4+int(block[2])<<12, 3
+		}
+		if int64(size) > blockSize {
+			return 0, ErrData
+		}
+
+		if litType == literalsRaw {
+			if len(block) < n+size {
+				return 0, ErrData
+			}
+
+			f.literals = append(f.literals[:0], block[n:n+size]...)
+			return n + size, nil
+		}
+
+		if len(block) < n+1 {
+			return 0, ErrData
+		}
+
+		f.literals = f.literals[:0]
+		for i := 0; i < size; i++ {
+			f.literals = append(f.literals, block[n])
+		}
+
+		return n + 1, nil
+	}
+
+	// Compressed literals have 1 stream with size format 0, otherwise 4, and
+	// the sizes take more bits in longer headers.
+	n, sizeBits := []int{3, 3, 4, 5}[sizeFormat], []uint{10, 10, 14, 18}[sizeFormat]
+	if len(block) < n {
+		return 0, ErrData
+	}
+
+	header := make([]byte, 8)
+	copy(header, block[:n])
+	sizes := binary.LittleEndian.Uint64(header) >> 4
+	size := int(sizes & (1<<sizeBits - 1))
+	compressed := int(sizes >> sizeBits & (1<<sizeBits - 1))
+	if int64(size) > blockSize || len(block) < n+compressed {
+		return 0, ErrData
+	}
+	data := block[n : n+compressed]
+
+	if litType == literalsCompressed {
+		table, tableSize, err := readHuffmanTable(data)
+		if err != nil {
+			return 0, err
+		}
+
+		f.huffman = table
+		data = data[tableSize:]
+	} else if f.huffman == nil {
+		return 0, ErrData
+	}
+
+	if cap(f.literals) < size {
+		f.literals = make([]byte, 0, size)
+	}
+	f.literals = f.literals[:0]
+
+	var err error
+	if sizeFormat == 0 {
+		f.literals, err = f.huffman.decode(f.literals, data, size)
+	} else {
+		f.literals, err = f.huffman.decode4(f.literals, data, size)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return n + compressed, nil
+}
+
+// readHuffmanTable reads the Huffman tree description at the start of data,
+// returning the table and the description's size.
+func readHuffmanTable(data []byte) (*huffmanTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, ErrData
+	}
+
+	var weights []byte
+	header := int(data[0])
+	size := 1
+	if header < 128 {
+		// The weights are FSE compressed.
+		size += header
+		if len(data) < size {
+			return nil, 0, ErrData
+		}
+
+		var err error
+		weights, err = readHuffmanWeights(data[1:size])
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		// The weights are stored directly, 4 bits each.
+		count := header - 127
+		size += (count + 1) / 2
+		if len(data) < size {
+			return nil, 0, ErrData
+		}
+
+		weights = make([]byte, count)
+		for i := range weights {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				b >>= 4
+			}
+			weights[i] = b & 0x0f
+		}
+	}
+
+	// The weight of the last symbol is implied by the sum of the others
+	// being a power of 2.
+	if len(weights) > 255 {
+		return nil, 0, ErrData
+	}
+	var total uint32
+	for _, w := range weights {
+		if w > maxHuffmanBits {
+			return nil, 0, ErrData
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return nil, 0, ErrData
+	}
+
+	maxBits := uint(bits.Len32(total))
+	rest := uint32(1)<<maxBits - total
+	if maxBits > maxHuffmanBits || rest&(rest-1) != 0 {
+		return nil, 0, ErrData
+	}
+	weights = append(weights, byte(bits.Len32(rest)))
+
+	// Codes are assigned from the lowest weight, each taking a range of the
+	// table the size of its weight.
+	table := &huffmanTable{entries: make([]huffmanEntry, 1<<maxBits), maxBits: maxBits}
+	pos := 0
+	for w := uint(1); w <= maxBits; w++ {
+		for symbol, sw := range weights {
+			if uint(sw) != w {
+				continue
+			}
+
+			entry := huffmanEntry{symbol: byte(symbol), nbBits: uint8(maxBits + 1 - w)}
+			for i := 0; i < 1<<(w-1); i++ {
+				table.entries[pos] = entry
+				pos++
+			}
+		}
+	}
+
+	return table, size, nil
+}
+
+// readHuffmanWeights decodes FSE compressed Huffman weights, which use two
+// states alternately.
+func readHuffmanWeights(data []byte) ([]byte, error) {
+	table, n, err := readFSETable(data, 6, 255)
+	if err != nil {
+		return nil, err
+	}
+
+	br, err := newBackwardBits(data[n:])
+	if err != nil {
+		return nil, err
+	}
+
+	var states [2]fseState
+	states[0].init(br, table)
+	states[1].init(br, table)
+
+	// The data ends once the bits run out, the other state then has the
+	// last weight.
+	var weights []byte
+	for i := 0; ; i ^= 1 {
+		if len(weights) >= 255 {
+			return nil, ErrData
+		}
+
+		weights = append(weights, states[i].symbol())
+		states[i].update(br)
+		if br.overrun > 0 {
+			return append(weights, states[i^1].symbol()), nil
+		}
+	}
+}
+
+// decode decodes size symbols from the Huffman stream data, appending them to
+// out.
+func (ht *huffmanTable) decode(out, data []byte, size int) ([]byte, error) {
+	br, err := newBackwardBits(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < size; i++ {
+		entry := ht.entries[br.peek(ht.maxBits)]
+		br.consume(uint(entry.nbBits))
+		out = append(out, entry.symbol)
+	}
+	if !br.finished() {
+		return nil, ErrData
+	}
+
+	return out, nil
+}
+
+// decode4 decodes size symbols from 4 Huffman streams, which follow a jump
+// table of their sizes.
+func (ht *huffmanTable) decode4(out, data []byte, size int) ([]byte, error) {
+	if len(data) < 6 {
+		return nil, ErrData
+	}
+
+	// Each stream has a quarter of the symbols, the last has the rest.
+	segment := (size + 3) / 4
+	if 3*segment > size {
+		return nil, ErrData
+	}
+
+	jumps, data := data[:6], data[6:]
+	start := 0
+	for i := 0; i < 4; i++ {
+		end, count := len(data), size-3*segment
+		if i < 3 {
+			end = start + int(binary.LittleEndian.Uint16(jumps[2*i:]))
+			count = segment
+		}
+		if end > len(data) || start > end {
+			return nil, ErrData
+		}
+
+		var err error
+		out, err = ht.decode(out, data[start:end], count)
+		if err != nil {
+			return nil, err
+		}
+		start = end
+	}
+
+	return out, nil
+}
@@ -0,0 +1,284 @@
+package zstd
+
+// Symbol compression modes of the sequences section.
+const (
+	modePredefined = iota
+	modeRLE
+	modeCompressed
+	modeRepeat
+)
+
+// Kinds of the sequence codes, the order their tables are described in.
+const (
+	literalsLength = iota
+	offset
+	matchLength
+)
+
+// codeTable describes a kind of sequence code: the values each code is
+// based on and the number of extra bits read, and its tables.
+type codeTable struct {
+	baselines  []uint32
+	extraBits  []uint8
+	maxLog     uint
+	predefined *fseTable
+}
+
+var codeTables = [3]codeTable{
+	literalsLength: {
+		baselines: []uint32{
+			0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+			16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+			8192, 16384, 32768, 65536,
+		},
+		extraBits: []uint8{
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+			13, 14, 15, 16,
+		},
+		maxLog: 9,
+		predefined: mustBuildFSETable([]int{
+			4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+			2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+			-1, -1, -1, -1,
+		}, 6),
+	},
+	offset: {
+		// Offset codes are the number of extra bits, the baseline is set by
+		// the decoder.
+		maxLog: 8,
+		predefined: mustBuildFSETable([]int{
+			1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+		}, 5),
+	},
+	matchLength: {
+		baselines: []uint32{
+			3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+			19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+			35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+			4099, 8195, 16387, 32771, 65539,
+		},
+		extraBits: []uint8{
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+			1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+			12, 13, 14, 15, 16,
+		},
+		maxLog: 9,
+		predefined: mustBuildFSETable([]int{
+			1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+			1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+			-1, -1, -1, -1, -1,
+		}, 6),
+	},
+}
+
+// maxOffsetCode is the largest offset code, which reads that many bits.
+const maxOffsetCode = 31
+
+// mustBuildFSETable builds the table for the predefined probabilities norm.
+func mustBuildFSETable(norm []int, accLog uint) *fseTable {
+	table, err := buildFSETable(norm, accLog)
+	if err != nil {
+		panic(err)
+	}
+
+	return table
+}
+
+// maxSymbol returns the largest code of the kind.
+func (ct *codeTable) maxSymbol() int {
+	if ct.baselines == nil {
+		return maxOffsetCode
+	}
+
+	return len(ct.baselines) - 1
+}
+
+// executeSequences decodes the sequences section data, appending the
+// literals and matches of the block to the history. The block decompresses
+// to at most blockSize bytes.
+func (f *frame) executeSequences(data []byte, blockSize int64) error {
+	if len(data) == 0 {
+		return ErrData
+	}
+
+	count, n := int(data[0]), 1
+	switch {
+	case count == 255:
+		if len(data) < 3 {
+			return ErrData
+		}
+		count, n = int(data[1])+int(data[2])<<8+0x7f00, 3
+	case count >= 128:
+		if len(data) < 2 {
+			return ErrData
+		}
+		count, n = (count-128)<<8+int(data[1]), 2
+	}
+
+	start := len(f.history)
+	if count == 0 {
+		if n != len(data) || int64(len(f.literals)) > blockSize {
+			return ErrData
+		}
+
+		f.history = append(f.history, f.literals...)
+		return nil
+	}
+
+	if len(data) <= n {
+		return ErrData
+	}
+	modes := data[n]
+	n++
+	if modes&0x03 != 0 {
+		return ErrData
+	}
+
+	for kind := range codeTables {
+		m, err := f.readSequenceTable(kind, modes>>(6-2*kind)&0x03, data[n:])
+		if err != nil {
+			return err
+		}
+		n += m
+	}
+
+	br, err := newBackwardBits(data[n:])
+	if err != nil {
+		return err
+	}
+
+	var states [3]fseState
+	for kind := range states {
+		states[kind].init(br, f.tables[kind])
+	}
+
+	literals := f.literals
+	for i := 0; i < count; i++ {
+		llCode := int(states[literalsLength].symbol())
+		ofCode := uint(states[offset].symbol())
+		mlCode := int(states[matchLength].symbol())
+		ll, ml := &codeTables[literalsLength], &codeTables[matchLength]
+		if llCode > ll.maxSymbol() || ofCode > maxOffsetCode || mlCode > ml.maxSymbol() {
+			return ErrData
+		}
+
+		offsetValue := uint32(1)<<ofCode + uint32(br.read(ofCode))
+		matchLen := int(ml.baselines[mlCode]) + int(br.read(uint(ml.extraBits[mlCode])))
+		litLen := int(ll.baselines[llCode]) + int(br.read(uint(ll.extraBits[llCode])))
+
+		if i < count-1 {
+			states[literalsLength].update(br)
+			states[matchLength].update(br)
+			states[offset].update(br)
+		}
+
+		off, err := f.resolveOffset(offsetValue, litLen)
+		if err != nil {
+			return err
+		}
+
+		if litLen > len(literals) {
+			return ErrData
+		}
+		f.history = append(f.history, literals[:litLen]...)
+		literals = literals[litLen:]
+
+		if int64(off) > f.windowSize || int(off) > len(f.history) {
+			return ErrData
+		}
+		if int64(len(f.history)-start+matchLen) > blockSize {
+			return ErrData
+		}
+
+		// Matches may overlap the bytes they produce.
+		from := len(f.history) - int(off)
+		if int(off) >= matchLen {
+			f.history = append(f.history, f.history[from:from+matchLen]...)
+		} else {
+			for j := 0; j < matchLen; j++ {
+				f.history = append(f.history, f.history[from+j])
+			}
+		}
+	}
+	if !br.finished() {
+		return ErrData
+	}
+
+	f.history = append(f.history, literals...)
+	if int64(len(f.history)-start) > blockSize {
+		return ErrData
+	}
+
+	return nil
+}
+
+// readSequenceTable sets the table for kind from its mode, reading its
+// description from the start of data. The size of the description is
+// returned.
+func (f *frame) readSequenceTable(kind int, mode byte, data []byte) (int, error) {
+	ct := &codeTables[kind]
+
+	switch mode {
+	case modePredefined:
+		f.tables[kind] = ct.predefined
+	case modeRLE:
+		if len(data) == 0 || int(data[0]) > ct.maxSymbol() {
+			return 0, ErrData
+		}
+
+		f.tables[kind] = rleFSETable(data[0])
+		return 1, nil
+	case modeCompressed:
+		table, n, err := readFSETable(data, ct.maxLog, ct.maxSymbol())
+		if err != nil {
+			return 0, err
+		}
+
+		f.tables[kind] = table
+		return n, nil
+	case modeRepeat:
+		if f.tables[kind] == nil {
+			return 0, ErrData
+		}
+	}
+
+	return 0, nil
+}
+
+// resolveOffset returns the offset for the offset value of a sequence,
+// updating the repeated offsets. Values up to 3 are repeated offsets, which
+// are shifted by one when the literals length is zero.
+func (f *frame) resolveOffset(value uint32, litLen int) (uint32, error) {
+	if value > 3 {
+		f.reps[2], f.reps[1], f.reps[0] = f.reps[1], f.reps[0], value-3
+		return f.reps[0], nil
+	}
+
+	if litLen == 0 {
+		value++
+	}
+
+	var off uint32
+	switch value {
+	case 1:
+		return f.reps[0], nil
+	case 2:
+		off = f.reps[1]
+	case 3:
+		off = f.reps[2]
+		f.reps[2] = f.reps[1]
+	case 4:
+		off = f.reps[0] - 1
+		f.reps[2] = f.reps[1]
+		if off == 0 {
+			return 0, ErrData
+		}
+	}
+	f.reps[1], f.reps[0] = f.reps[0], off
+
+	return off, nil
+}
@@ -0,0 +1,106 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// XXH64 primes.
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+// digest computes the XXH64 hash with a zero seed, which frames' content
+// checksums are the low 32 bits of.
+type digest struct {
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int // Bytes of mem used.
+}
+
+func newDigest() *digest {
+	// The accumulators start at prime1 + prime2, prime2, 0 and -prime1,
+	// wrapping around.
+	return &digest{v: [4]uint64{6983438078262162902, prime2, 0, 7046029288634856825}}
+}
+
+// Write adds p to the hash.
+func (d *digest) Write(p []byte) {
+	d.total += uint64(len(p))
+
+	if d.n > 0 {
+		n := copy(d.mem[d.n:], p)
+		d.n += n
+		p = p[n:]
+		if d.n < len(d.mem) {
+			return
+		}
+
+		d.stripe(d.mem[:])
+		d.n = 0
+	}
+
+	for ; len(p) >= len(d.mem); p = p[len(d.mem):] {
+		d.stripe(p)
+	}
+	d.n = copy(d.mem[:], p)
+}
+
+// stripe adds the 32 bytes at the start of p to the accumulators.
+func (d *digest) stripe(p []byte) {
+	for i := range d.v {
+		d.v[i] = round(d.v[i], binary.LittleEndian.Uint64(p[8*i:]))
+	}
+}
+
+// Sum64 returns the hash of the data written.
+func (d *digest) Sum64() uint64 {
+	var h uint64
+	if d.total >= 32 {
+		v := d.v
+		h = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) +
+			bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, x := range v {
+			h = (h^round(0, x))*prime1 + prime4
+		}
+	} else {
+		h = prime5
+	}
+	h += d.total
+
+	p := d.mem[:d.n]
+	for ; len(p) >= 8; p = p[8:] {
+		h ^= round(0, binary.LittleEndian.Uint64(p))
+		h = bits.RotateLeft64(h, 27)*prime1 + prime4
+	}
+	if len(p) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(p)) * prime1
+		h = bits.RotateLeft64(h, 23)*prime2 + prime3
+		p = p[4:]
+	}
+	for _, b := range p {
+		h ^= uint64(b) * prime5
+		h = bits.RotateLeft64(h, 11) * prime1
+	}
+
+	h ^= h >> 33
+	h *= prime2
+	h ^= h >> 29
+	h *= prime3
+	h ^= h >> 32
+
+	return h
+}
+
+// round mixes input into the accumulator acc.
+func round(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+
+	return acc * prime1
+}
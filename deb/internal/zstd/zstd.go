@@ -0,0 +1,344 @@
+// Package zstd implements reading of Zstandard compressed data, which
+// dpkg-deb compresses tarballs with on some distributions. Frames using
+// dictionaries aren't supported.
+//
+// References:
+//
+//	https://www.rfc-editor.org/rfc/rfc8878
+package zstd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	ErrFormat     = errors.New("zstd: invalid format")
+	ErrData       = errors.New("zstd: invalid compressed data")
+	ErrChecksum   = errors.New("zstd: checksum mismatch")
+	ErrDictionary = errors.New("zstd: dictionaries aren't supported")
+	ErrWindowSize = errors.New("zstd: window too large")
+)
+
+// Magic numbers of frames, skippable frames use any of the 16 starting at
+// skippableMagic.
+const (
+	frameMagic     = 0xfd2fb528
+	skippableMagic = 0x184d2a50
+	skippableMask  = 0xfffffff0
+)
+
+// Limits from the format, windows are limited like the reference decoder.
+const (
+	maxBlockSize  = 128 << 10
+	maxWindowSize = 1 << 27
+)
+
+// Block types.
+const (
+	blockRaw = iota
+	blockRLE
+	blockCompressed
+)
+
+// Reader decompresses Zstandard data read from an underlying reader.
+// Concatenated frames are read as one, and skippable frames are skipped.
+type Reader struct {
+	src   *bufio.Reader
+	frame *frame // The current frame, nil between frames.
+	out   []byte // Decompressed data that hasn't been read.
+	err   error
+}
+
+// frame is the state of the frame being decompressed.
+type frame struct {
+	windowSize  int64
+	contentSize int64 // Decompressed size from the header, or -1.
+	decoded     int64 // Bytes decompressed.
+	checksum    *digest
+	last        bool   // If the last block has been read.
+	history     []byte // Recent decompressed data matches are copied from.
+	block       []byte // Compressed data of the current block.
+	literals    []byte // Literals of the current block.
+	reps        [3]uint32
+	huffman     *huffmanTable // Table for treeless literals.
+	tables      [3]*fseTable  // Tables for repeated sequence modes.
+}
+
+// NewReader creates a Reader decompressing r, reading the first frame
+// header. ErrFormat is returned if r doesn't start with one.
+func NewReader(r io.Reader) (*Reader, error) {
+	z := &Reader{src: bufio.NewReader(r)}
+
+	err := z.readFrameHeader()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return z, nil
+}
+
+// Read reads decompressed data into p. At the end of the data the frame
+// checksums have been verified, and io.EOF is returned.
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+
+		z.err = z.next()
+	}
+
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+
+	return n, nil
+}
+
+// next decompresses the next block, reading the frame headers and checksums
+// around them.
+func (z *Reader) next() error {
+	switch {
+	case z.frame == nil:
+		return z.readFrameHeader()
+	case z.frame.last:
+		return z.finishFrame()
+	}
+
+	return z.readBlock()
+}
+
+// readFrameHeader reads the next frame header, skipping skippable frames. It
+// returns io.EOF if there's no data.
+func (z *Reader) readFrameHeader() error {
+	for {
+		var magic [4]byte
+		n, err := io.ReadFull(z.src, magic[:])
+		if n == 0 && err == io.EOF {
+			return io.EOF
+		}
+		if err != nil {
+			return unexpected(err)
+		}
+
+		m := binary.LittleEndian.Uint32(magic[:])
+		if m == frameMagic {
+			break
+		}
+		if m&skippableMask != skippableMagic {
+			return ErrFormat
+		}
+
+		_, err = io.ReadFull(z.src, magic[:])
+		if err != nil {
+			return unexpected(err)
+		}
+		_, err = z.src.Discard(int(binary.LittleEndian.Uint32(magic[:])))
+		if err != nil {
+			return unexpected(err)
+		}
+	}
+
+	descriptor, err := z.src.ReadByte()
+	if err != nil {
+		return unexpected(err)
+	}
+	fcsFlag := descriptor >> 6
+	single := descriptor&0x20 != 0
+	if descriptor&0x08 != 0 {
+		return ErrFormat
+	}
+
+	f := &frame{contentSize: -1, reps: [3]uint32{1, 4, 8}}
+	if descriptor&0x04 != 0 {
+		f.checksum = newDigest()
+	}
+
+	if !single {
+		wd, err := z.src.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+
+		base := int64(1) << (10 + wd>>3)
+		f.windowSize = base + base/8*int64(wd&0x07)
+	}
+
+	// Only frames without a dictionary are supported, the ID can be zero.
+	id, err := z.readUint([]int{0, 1, 2, 4}[descriptor&0x03])
+	if err != nil {
+		return err
+	}
+	if id != 0 {
+		return ErrDictionary
+	}
+
+	fcsSize := []int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && single {
+		fcsSize = 1
+	}
+	if fcsSize > 0 {
+		size, err := z.readUint(fcsSize)
+		if err != nil {
+			return err
+		}
+		if fcsSize == 2 {
+			size += 256
+		}
+
+		f.contentSize = int64(size)
+		if f.contentSize < 0 {
+			return ErrWindowSize
+		}
+	}
+
+	if single {
+		f.windowSize = f.contentSize
+	}
+	if f.windowSize > maxWindowSize {
+		return ErrWindowSize
+	}
+
+	z.frame = f
+	return nil
+}
+
+// readUint reads an n byte little endian integer.
+func (z *Reader) readUint(n int) (uint64, error) {
+	b := make([]byte, 8)
+	_, err := io.ReadFull(z.src, b[:n])
+	if err != nil {
+		return 0, unexpected(err)
+	}
+
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+// readBlock reads and decompresses the next block of the frame.
+func (z *Reader) readBlock() error {
+	f := z.frame
+	header, err := z.readUint(3)
+	if err != nil {
+		return err
+	}
+	f.last = header&1 != 0
+	size := int(header >> 3)
+
+	blockSize := int64(maxBlockSize)
+	if f.windowSize < blockSize {
+		blockSize = f.windowSize
+	}
+
+	// Keep the window, dropping older data once it's twice the size so it's
+	// only copied occasionally.
+	if int64(len(f.history)) > 2*f.windowSize+maxBlockSize {
+		keep := f.history[int64(len(f.history))-f.windowSize:]
+		f.history = append(f.history[:0], keep...)
+	}
+	start := len(f.history)
+
+	switch header >> 1 & 0x03 {
+	case blockRaw:
+		if int64(size) > blockSize {
+			return ErrData
+		}
+
+		f.history = append(f.history, make([]byte, size)...)
+		_, err = io.ReadFull(z.src, f.history[start:])
+		if err != nil {
+			return unexpected(err)
+		}
+	case blockRLE:
+		if int64(size) > blockSize {
+			return ErrData
+		}
+
+		b, err := z.src.ReadByte()
+		if err != nil {
+			return unexpected(err)
+		}
+		for i := 0; i < size; i++ {
+			f.history = append(f.history, b)
+		}
+	case blockCompressed:
+		if int64(size) > blockSize {
+			return ErrData
+		}
+
+		if cap(f.block) < size {
+			f.block = make([]byte, size)
+		}
+		f.block = f.block[:size]
+		_, err = io.ReadFull(z.src, f.block)
+		if err != nil {
+			return unexpected(err)
+		}
+
+		err = f.decompressBlock(blockSize)
+		if err != nil {
+			return err
+		}
+	default:
+		return ErrData
+	}
+
+	out := f.history[start:]
+	f.decoded += int64(len(out))
+	if f.contentSize >= 0 && f.decoded > f.contentSize {
+		return ErrData
+	}
+	if f.checksum != nil {
+		f.checksum.Write(out)
+	}
+
+	z.out = out
+	return nil
+}
+
+// finishFrame verifies the size and checksum of the frame, and reads the
+// next frame header if there is one.
+func (z *Reader) finishFrame() error {
+	f := z.frame
+	z.frame = nil
+
+	if f.contentSize >= 0 && f.decoded != f.contentSize {
+		return ErrData
+	}
+
+	if f.checksum != nil {
+		sum, err := z.readUint(4)
+		if err != nil {
+			return err
+		}
+		if uint32(sum) != uint32(f.checksum.Sum64()) {
+			return ErrChecksum
+		}
+	}
+
+	return z.readFrameHeader()
+}
+
+// decompressBlock decompresses the compressed block in f.block to the
+// history. The block decompresses to at most blockSize bytes.
+func (f *frame) decompressBlock(blockSize int64) error {
+	n, err := f.readLiterals(f.block, blockSize)
+	if err != nil {
+		return err
+	}
+
+	return f.executeSequences(f.block[n:], blockSize)
+}
+
+// unexpected converts io.EOF to io.ErrUnexpectedEOF, the data ends early.
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
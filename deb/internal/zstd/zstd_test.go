@@ -0,0 +1,96 @@
+package zstd
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func decompress(data []byte) ([]byte, error) {
+	z, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(z)
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestRead(t *testing.T) {
+	words := readTestdata(t, "words.txt")
+	expected := map[string][]byte{
+		"words.zst":         words,
+		"words_19.zst":      words,
+		"words_nocheck.zst": words,
+		"words_stream.zst":  words,
+		"multi.zst":         append([]byte("hello\n"), words...),
+		"zeros.zst":         make([]byte, 300000),
+		"empty.zst":         []byte{},
+	}
+
+	for name, contents := range expected {
+		out, err := decompress(readTestdata(t, name))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+
+		if !bytes.Equal(out, contents) {
+			t.Errorf("%s doesn't decompress to its original contents.", name)
+		}
+	}
+}
+
+func TestReadSkippable(t *testing.T) {
+	data := append([]byte{0x50, 0x2a, 0x4d, 0x18, 4, 0, 0, 0, 's', 'k', 'i', 'p'}, readTestdata(t, "words.zst")...)
+
+	out, err := decompress(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, readTestdata(t, "words.txt")) {
+		t.Error("Skippable frames should be skipped.")
+	}
+}
+
+func TestReadCorrupt(t *testing.T) {
+	data := readTestdata(t, "words.zst")
+
+	_, err := decompress([]byte("not zstd compressed data"))
+	if err != ErrFormat {
+		t.Error("NewReader should return ErrFormat for data that isn't zstd.")
+	}
+
+	_, err = decompress(data[:len(data)/2])
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Read should return io.ErrUnexpectedEOF for truncated data.")
+	}
+
+	// The content checksum is the last 4 bytes of the frame.
+	checksum := append([]byte{}, data...)
+	checksum[len(checksum)-1] ^= 0xff
+	_, err = decompress(checksum)
+	if err != ErrChecksum {
+		t.Error("Read should return ErrChecksum for a frame that doesn't match its checksum.")
+	}
+
+	_, err = decompress([]byte{0x28, 0xb5, 0x2f, 0xfd, 0x01, 0x00, 0x05})
+	if err != ErrDictionary {
+		t.Error("NewReader should return ErrDictionary for frames using a dictionary.")
+	}
+
+	_, err = decompress([]byte{0x28, 0xb5, 0x2f, 0xfd, 0x00, 0x90})
+	if err != ErrWindowSize {
+		t.Error("NewReader should return ErrWindowSize for windows over 128MiB.")
+	}
+}
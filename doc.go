@@ -1,9 +1,17 @@
 // Package ar implements access to read and write ar archives.
 //
-// Reading supports both GNU, BSD, and Go ar variants, and writing creates
-// archives of the GNU variant.
+// Reading supports both GNU, GNU thin, BSD, COFF, AIX big, and Go ar
+// variants, and writing creates archives of the GNU variant by default, or of
+// the GNU thin, BSD, COFF or AIX big variants.
+//
+// The symbol table written is created from the members a SymbolExtractor
+// understands, ELF, Mach-O, COFF, XCOFF, Go and WebAssembly objects, and LLVM
+// bitcode by default.
 //
 // References:
 //   https://mebsd.com/man/ar/5
 //   http://www.unix.com/man-page/all/3head/ar.h/
+//   https://learn.microsoft.com/en-us/windows/win32/debug/pe-format#archive-library-file-format
+//   https://www.ibm.com/docs/en/aix/7.3?topic=formats-ar-file-format-big
+//   https://www.ibm.com/docs/en/aix/7.3?topic=formats-xcoff-object-file-format
 package ar
@@ -0,0 +1,49 @@
+package ar
+
+import (
+	"debug/elf"
+	"io"
+)
+
+// elfMagic identifies ELF object files.
+var elfMagic = []byte(elf.ELFMAG)
+
+// elfSymbols returns the names of the global and weak symbols defined by the
+// ELF object read from r, the same symbols GNU ar adds to the archive index.
+func elfSymbols(r io.ReaderAt) ([]string, error) {
+	file, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	syms, err := file.Symbols()
+	if err != nil {
+		if err == elf.ErrNoSymbols {
+			err = nil
+		}
+
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, sym := range syms {
+		bind := elf.ST_BIND(sym.Info)
+		if bind != elf.STB_GLOBAL && bind != elf.STB_WEAK && bind != elf.STB_LOOS {
+			continue
+		}
+
+		typ := elf.ST_TYPE(sym.Info)
+		if typ == elf.STT_SECTION || typ == elf.STT_FILE {
+			continue
+		}
+
+		if sym.Section == elf.SHN_UNDEF || sym.Name == "" {
+			continue
+		}
+
+		names = append(names, sym.Name)
+	}
+
+	return names, nil
+}
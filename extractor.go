@@ -0,0 +1,159 @@
+package ar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+var (
+	ErrUnsupportedObject = errors.New("ar: object symbols can't be extracted")
+)
+
+// SymbolError records the entry whose symbols couldn't be added to the symbol
+// table. It wraps ErrUnsupportedObject.
+type SymbolError struct {
+	Name string // Name of the entry.
+	Err  error  // ErrUnsupportedObject or an error wrapping it.
+}
+
+func (e *SymbolError) Error() string {
+	return fmt.Sprintf("%v: entry %q", e.Err, e.Name)
+}
+
+// Unwrap returns the error from the SymbolExtractor.
+func (e *SymbolError) Unwrap() error {
+	return e.Err
+}
+
+// SymbolExtractor returns the symbols defined by an archive member, which the
+// Writer adds to the symbol table mapping to the member. The size bytes of
+// contents are read from r, which is only valid during the call. An error
+// leaves the member out of the table, e.g. if the contents can't be parsed.
+// Errors wrapping ErrUnsupportedObject, for objects that are recognized but
+// whose symbols can't be read, are returned by the Writer as a *SymbolError
+// instead. COFF and Mach-O objects are only linked from COFF and BSD archives,
+// so they're left out of the table in other formats. Symbols aren't extracted
+// for FormatCommon archives, which have no table.
+type SymbolExtractor interface {
+	ExtractSymbols(name string, r io.ReaderAt, size int64) ([]string, error)
+}
+
+// SymbolExtractorFunc is a function used as a SymbolExtractor.
+type SymbolExtractorFunc func(name string, r io.ReaderAt, size int64) ([]string, error)
+
+// ExtractSymbols calls fn(name, r, size).
+func (fn SymbolExtractorFunc) ExtractSymbols(name string, r io.ReaderAt, size int64) ([]string, error) {
+	return fn(name, r, size)
+}
+
+var (
+	extractorsMu sync.RWMutex
+	extractors   = make(map[string]SymbolExtractor)
+)
+
+func init() {
+	RegisterSymbolExtractor(string(elfMagic), readerAtExtractor(elfSymbols))
+	for _, magic := range machoMagics {
+		RegisterSymbolExtractor(string(magic), readerAtExtractor(machoSymbols))
+	}
+	for _, magic := range coffMagics {
+		RegisterSymbolExtractor(string(magic), readerAtExtractor(coffSymbols))
+	}
+	RegisterSymbolExtractor(string(xcoffMagic), readerAtExtractor(xcoffSymbols))
+	RegisterSymbolExtractor(string(xcoff64Magic), readerAtExtractor(xcoffSymbols))
+	RegisterSymbolExtractor(goobjHeaderMagic, contentsExtractor(goobjSymbols))
+	RegisterSymbolExtractor(string(wasmMagic), contentsExtractor(wasmSymbols))
+	RegisterSymbolExtractor(string(bitcodeMagic), contentsExtractor(bitcodeSymbols))
+	RegisterSymbolExtractor(string(bitcodeWrapperMagic), contentsExtractor(bitcodeSymbols))
+}
+
+// RegisterSymbolExtractor registers a SymbolExtractor for members whose
+// contents start with magic, replacing any registered for the same magic. If
+// multiple magic numbers match a member the longest is used. ELF, Mach-O,
+// COFF, XCOFF, Go and WebAssembly objects, and LLVM bitcode are supported by
+// default.
+func RegisterSymbolExtractor(magic string, extractor SymbolExtractor) {
+	extractorsMu.Lock()
+	defer extractorsMu.Unlock()
+
+	extractors[magic] = extractor
+}
+
+// symbolExtractor returns the SymbolExtractor for contents, or nil if no
+// magic number matches it.
+func symbolExtractor(contents []byte) SymbolExtractor {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	var extractor SymbolExtractor
+	longest := -1
+	for magic, ex := range extractors {
+		if len(magic) > longest && bytes.HasPrefix(contents, []byte(magic)) {
+			extractor = ex
+			longest = len(magic)
+		}
+	}
+
+	return extractor
+}
+
+// maybeExtractable checks if contents could begin with a registered magic
+// number, either matching it or being a prefix of it.
+func maybeExtractable(contents []byte) bool {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	for magic := range extractors {
+		n := len(contents)
+		if n > len(magic) {
+			n = len(magic)
+		}
+
+		if string(contents[:n]) == magic[:n] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// longestMagic returns the length of the longest registered magic number,
+// the most of a member's contents needed to pick its SymbolExtractor.
+func longestMagic() int {
+	extractorsMu.RLock()
+	defer extractorsMu.RUnlock()
+
+	longest := 0
+	for magic := range extractors {
+		if len(magic) > longest {
+			longest = len(magic)
+		}
+	}
+
+	return longest
+}
+
+// readerAtExtractor adapts a parser reading from an io.ReaderAt to a
+// SymbolExtractor.
+func readerAtExtractor(fn func(r io.ReaderAt) ([]string, error)) SymbolExtractor {
+	return SymbolExtractorFunc(func(name string, r io.ReaderAt, size int64) ([]string, error) {
+		return fn(io.NewSectionReader(r, 0, size))
+	})
+}
+
+// contentsExtractor adapts a parser of the whole contents to a
+// SymbolExtractor, the contents are read into memory for each call.
+func contentsExtractor(fn func(name string, contents []byte) ([]string, error)) SymbolExtractor {
+	return SymbolExtractorFunc(func(name string, r io.ReaderAt, size int64) ([]string, error) {
+		contents, err := ioutil.ReadAll(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, err
+		}
+
+		return fn(name, contents)
+	})
+}
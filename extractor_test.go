@@ -0,0 +1,84 @@
+package ar
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRegisterSymbolExtractor(t *testing.T) {
+	t.Cleanup(func() {
+		extractorsMu.Lock()
+		delete(extractors, "TESTOBJ")
+		delete(extractors, "TESTOBJ2")
+		extractorsMu.Unlock()
+	})
+
+	RegisterSymbolExtractor("TESTOBJ", SymbolExtractorFunc(func(name string, r io.ReaderAt, size int64) ([]string, error) {
+		if name == "invalid.obj" {
+			return nil, errors.New("invalid object")
+		}
+
+		contents := make([]byte, size)
+		_, err := r.ReadAt(contents, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.Fields(string(contents[len("TESTOBJ"):])), nil
+	}))
+	RegisterSymbolExtractor("TESTOBJ2", SymbolExtractorFunc(func(name string, r io.ReaderAt, size int64) ([]string, error) {
+		return []string{"longest_" + name}, nil
+	}))
+
+	var out bytes.Buffer
+	arWriter := NewWriter(&out)
+	files := map[string]string{
+		"a.obj":       "TESTOBJ first second",
+		"b.obj":       "TESTOBJ2 ignored",
+		"invalid.obj": "TESTOBJ invalid",
+		"notes.txt":   "TEST notes",
+	}
+	for _, name := range []string{"a.obj", "b.obj", "invalid.obj", "notes.txt"} {
+		err := arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write([]byte(files[name]))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader := NewReader(&out)
+	for {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil {
+			break
+		}
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 3 {
+		t.Fatal("Symbol table should contain the symbols from the registered extractors.")
+	}
+
+	expected := map[string]string{"first": "a.obj", "second": "a.obj", "longest_b.obj": "b.obj"}
+	for name, member := range expected {
+		sym := symbols.Lookup(name)
+		if sym == nil || sym.Member != member {
+			t.Errorf("Symbol %s should be defined by %s.", name, member)
+		}
+	}
+}
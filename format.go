@@ -0,0 +1,33 @@
+package ar
+
+// Format represents a variant of the ar archive format.
+type Format int
+
+const (
+	// FormatGNU is the GNU/SVR4 variant, names are terminated with / and long
+	// names are stored in the // strings table.
+	FormatGNU Format = iota
+
+	// FormatBSD is the BSD variant used by Apple tooling, names are stored
+	// after the header as #1/len and member contents are 8 byte aligned.
+	FormatBSD
+
+	// FormatGNUThin is the GNU thin variant, file entries only reference
+	// external files by path and their contents aren't stored.
+	FormatGNUThin
+
+	// FormatCommon is the common format without extensions used by Debian
+	// packages, names are limited to 16 bytes and there are no symbol or
+	// strings tables.
+	FormatCommon
+
+	// FormatCOFF is the Microsoft variant used for Windows .lib files, the GNU
+	// symbol table is followed by a second linker member with the member
+	// offsets and sorted symbols, and long names are terminated with nulls.
+	FormatCOFF
+
+	// FormatAIXBig is the AIX big archive variant, which has its own magic
+	// number and header layout. Members are linked by their offsets and
+	// followed by the member table and global symbol table.
+	FormatAIXBig
+)
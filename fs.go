@@ -0,0 +1,211 @@
+package ar
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"time"
+)
+
+// FS provides access to the file entries of an indexed archive as a file
+// system. Entries are arranged into directories by splitting their names on
+// /, and entries whose names aren't valid fs paths, or that conflict with an
+// earlier entry, are left out.
+type FS struct {
+	archive *ReaderAt
+	files   map[string]*Header
+	dirs    map[string][]fs.DirEntry // Contains the sorted entries for each directory.
+}
+
+// NewFS creates a FS from the file entries in archive.
+func NewFS(archive *ReaderAt) *FS {
+	fsys := &FS{
+		archive: archive,
+		files:   make(map[string]*Header),
+		dirs:    map[string][]fs.DirEntry{".": make([]fs.DirEntry, 0)},
+	}
+
+	for _, header := range archive.Members() {
+		if !fsys.canAdd(header.Name) {
+			continue
+		}
+
+		fsys.files[header.Name] = header
+		fsys.addEntry(header.Name, fs.FileInfoToDirEntry(header.FileInfo()))
+	}
+
+	for _, entries := range fsys.dirs {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+	}
+
+	return fsys
+}
+
+// Open opens the named file or directory.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if entries, ok := fsys.dirs[name]; ok {
+		return &fsDir{info: &fsDirInfo{name: path.Base(name)}, entries: entries}, nil
+	}
+
+	header, ok := fsys.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	section := fsys.archive.Section(header)
+	if section == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: ErrThinMember}
+	}
+
+	return &fsFile{SectionReader: section, header: header}, nil
+}
+
+// ReadDir reads the named directory, returning its entries sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries, ok := fsys.dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	list := make([]fs.DirEntry, len(entries))
+	copy(list, entries)
+
+	return list, nil
+}
+
+// Stat returns the file info for the named file or directory.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if _, ok := fsys.dirs[name]; ok {
+		return &fsDirInfo{name: path.Base(name)}, nil
+	}
+
+	header, ok := fsys.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return header.FileInfo(), nil
+}
+
+// ReadFile reads the contents of the named file.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	contents, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: err}
+	}
+
+	return contents, nil
+}
+
+// canAdd checks if name is a valid path that doesn't conflict with existing
+// files or directories.
+func (fsys *FS) canAdd(name string) bool {
+	if !fs.ValidPath(name) || name == "." {
+		return false
+	}
+
+	if _, ok := fsys.files[name]; ok {
+		return false
+	}
+	if _, ok := fsys.dirs[name]; ok {
+		return false
+	}
+
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := fsys.files[dir]; ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// addEntry adds entry to the directory containing name, creating any parent
+// directories that don't exist.
+func (fsys *FS) addEntry(name string, entry fs.DirEntry) {
+	dir := path.Dir(name)
+
+	if _, ok := fsys.dirs[dir]; !ok {
+		fsys.dirs[dir] = make([]fs.DirEntry, 0)
+		fsys.addEntry(dir, fs.FileInfoToDirEntry(&fsDirInfo{name: path.Base(dir)}))
+	}
+
+	fsys.dirs[dir] = append(fsys.dirs[dir], entry)
+}
+
+// fsFile implements fs.File for a file entry.
+type fsFile struct {
+	*io.SectionReader
+	header *Header
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) { return f.header.FileInfo(), nil }
+func (f *fsFile) Close() error               { return nil }
+
+// fsDir implements fs.ReadDirFile for a directory.
+type fsDir struct {
+	info    *fsDirInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *fsDir) Close() error               { return nil }
+
+func (d *fsDir) Read(b []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir returns the next n entries, or all remaining entries if n <= 0.
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return append([]fs.DirEntry(nil), remaining...), nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+
+	return append([]fs.DirEntry(nil), remaining[:n]...), nil
+}
+
+// fsDirInfo implements fs.FileInfo for a directory.
+type fsDirInfo struct {
+	name string
+}
+
+func (fi *fsDirInfo) Name() string       { return fi.name }
+func (fi *fsDirInfo) Size() int64        { return 0 }
+func (fi *fsDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (fi *fsDirInfo) ModTime() time.Time { return time.Time{} }
+func (fi *fsDirInfo) IsDir() bool        { return true }
+func (fi *fsDirInfo) Sys() interface{}   { return nil }
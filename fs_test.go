@@ -0,0 +1,66 @@
+package ar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	var out bytes.Buffer
+	arWriter := NewWriter(&out)
+
+	object, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{
+		"exit.o":                   object,
+		"docs/readme.txt":          []byte("odd sized\n"),
+		"docs/nested/long_name.md": []byte("# Nested\n"),
+		"../escape.o":              object,
+	}
+	for _, name := range []string{"exit.o", "docs/readme.txt", "docs/nested/long_name.md", "../escape.o"} {
+		err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := NewFS(arReader)
+
+	err = fstest.TestFS(fsys, "exit.o", "docs/readme.txt", "docs/nested/long_name.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := fsys.ReadFile("docs/readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(contents, files["docs/readme.txt"]) {
+		t.Error("File contents don't match the written file.")
+	}
+
+	_, err = fsys.Stat("../escape.o")
+	if err == nil {
+		t.Error("Entries with invalid paths shouldn't be in the file system.")
+	}
+}
@@ -0,0 +1,145 @@
+package ar
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fuzzLimits keeps hostile inputs from exhausting memory while fuzzing.
+var fuzzLimits = ReaderOptions{
+	GoMetadata:    true,
+	MaxTableSize:  1 << 20,
+	MaxNameLength: 4096,
+	MaxMembers:    1024,
+	MaxTotalSize:  1 << 24,
+}
+
+// addArchiveSeeds adds the test archives and corpus to the fuzzing seeds.
+func addArchiveSeeds(f *testing.F) {
+	for _, pattern := range []string{"*.a", "*.lib", filepath.Join("corpus", "*.a"), filepath.Join("corpus", "*.lib")} {
+		paths, err := filepath.Glob(filepath.Join("testdata", pattern))
+		if err != nil {
+			f.Fatal(err)
+		}
+
+		for _, path := range paths {
+			archive, err := ioutil.ReadFile(path)
+			if err != nil {
+				f.Fatal(err)
+			}
+
+			f.Add(archive)
+		}
+	}
+}
+
+func FuzzReader(f *testing.F) {
+	addArchiveSeeds(f)
+
+	f.Fuzz(func(t *testing.T, archive []byte) {
+		arReader := NewReaderOptions(bytes.NewReader(archive), fuzzLimits)
+		for {
+			header, err := arReader.Next()
+			if err != nil || header == nil {
+				break
+			}
+
+			n, err := io.Copy(ioutil.Discard, arReader)
+			if err == nil && !arReader.Thin() && n != header.Size {
+				t.Fatalf("Read %d bytes of a %d byte entry.", n, header.Size)
+			}
+		}
+
+		ara, err := OpenReaderAtOptions(bytes.NewReader(archive), int64(len(archive)), fuzzLimits)
+		if err != nil {
+			return
+		}
+
+		for _, header := range ara.Members() {
+			section := ara.Section(header)
+			if section == nil {
+				continue
+			}
+
+			_, err = io.Copy(ioutil.Discard, section)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	for _, format := range []Format{FormatGNU, FormatBSD, FormatCOFF, FormatAIXBig, FormatCommon} {
+		f.Add(uint8(format), "exit.o", []byte("contents"))
+		f.Add(uint8(format), "a_rather_long_object_name.o", []byte("odd"))
+		f.Add(uint8(format), "empty", []byte{})
+		f.Add(uint8(format), "blob", []byte("\x00\x00\xff\xff\x01\x00data blob....."))
+	}
+
+	f.Fuzz(func(t *testing.T, format uint8, name string, contents []byte) {
+		// Thin archives don't store the contents.
+		if Format(format) > FormatAIXBig || Format(format) == FormatGNUThin {
+			t.Skip()
+		}
+
+		var out bytes.Buffer
+		arWriter, err := NewWriterOptions(&out, WriterOptions{Format: Format(format), ValidateNames: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		modTime := time.Unix(1700000000, 0)
+		for _, member := range []string{name, "last"} {
+			err = arWriter.WriteHeader(&Header{Name: member, ModTime: modTime, Mode: 0644, Size: int64(len(contents))})
+			if errors.Is(err, ErrHeaderTooLong) || errors.Is(err, ErrInvalidName) {
+				t.Skip()
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = arWriter.Write(contents)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = arWriter.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		arReader := NewReader(&out)
+		for _, member := range []string{name, "last"} {
+			header, err := arReader.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header == nil || header.Name != member {
+				t.Fatalf("Read entry %+v, expected %q.", header, member)
+			}
+			if !header.ModTime.Equal(modTime) || header.Size != int64(len(contents)) {
+				t.Fatalf("Read header %+v doesn't match the written header.", header)
+			}
+
+			read, err := ioutil.ReadAll(arReader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(read, contents) {
+				t.Fatal("Read contents don't match the written contents.")
+			}
+		}
+
+		header, err := arReader.Next()
+		if err != nil || header != nil {
+			t.Fatal("Archive should only contain the written entries.")
+		}
+	})
+}
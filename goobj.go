@@ -0,0 +1,225 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrGoObject = errors.New("ar: invalid Go object data")
+)
+
+// goobjMagic identifies the object data in a Go _go_.o entry.
+var goobjMagic = []byte("\x00go120ld")
+
+// goobjHeaderMagic identifies the header of Go __.PKGDEF and _go_.o entries.
+const goobjHeaderMagic = "go object "
+
+// Go object data layout, from cmd/internal/goobj. The block offsets follow
+// the magic, fingerprint and flags, and each block ends at the next offset.
+const (
+	goobjBlocks    = 20 // Offset to the block offsets.
+	goobjSymdef    = 3  // Block of package symbol definitions.
+	goobjNonpkgdef = 6  // Block of non package symbol definitions.
+	goobjSymSize   = 21 // Name, ABI, type, flag, flag2, size and alignment.
+	goobjFlagDupok = 1 << 0
+	goobjFlagLocal = 1 << 1
+)
+
+// GoHeader is the header the Go toolchain writes at the start of both the
+// __.PKGDEF and _go_.o entries.
+type GoHeader struct {
+	GOOS      string   // Target operating system.
+	GOARCH    string   // Target architecture.
+	GoVersion string   // Version of the compiler, e.g. go1.22.0.
+	Fields    []string // Remaining fields of the object line, e.g. GOAMD64=v1.
+	BuildID   string   // Build ID, if the compiler was given one.
+	Main      bool     // If the package is a main package.
+}
+
+// PkgDef is a parsed Go __.PKGDEF entry.
+type PkgDef struct {
+	GoHeader
+	ExportOffset int64  // Byte offset to the export data in the entry.
+	Export       []byte // Export data between the $$B and $$ markers.
+}
+
+// GoObject is a parsed Go _go_.o entry.
+type GoObject struct {
+	GoHeader
+	Imports []string // Import paths of the packages linked in by the object.
+}
+
+// ParsePkgDef parses the __.PKGDEF entry of a Go package archive read from r,
+// see ReaderOptions.GoMetadata.
+func ParsePkgDef(r io.Reader) (*PkgDef, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgdef := new(PkgDef)
+	offset, err := parseGoHeader(&pkgdef.GoHeader, contents, "$$B")
+	if err != nil {
+		return nil, err
+	}
+
+	end := bytes.LastIndex(contents, []byte("\n$$\n"))
+	if end < offset {
+		return nil, ErrGoObject
+	}
+	pkgdef.ExportOffset = int64(offset)
+	pkgdef.Export = contents[offset:end]
+
+	return pkgdef, nil
+}
+
+// ParseGoObject parses the _go_.o entry of a Go package archive read from r.
+func ParseGoObject(r io.Reader) (*GoObject, error) {
+	contents, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	object := new(GoObject)
+	offset, err := parseGoHeader(&object.GoHeader, contents, "!")
+	if err != nil {
+		return nil, err
+	}
+
+	object.Imports, err = goobjImports(contents[offset:])
+	if err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+// parseGoHeader parses the header lines of contents into header, up to the
+// line end. The offset following the end line is returned.
+func parseGoHeader(header *GoHeader, contents []byte, end string) (int, error) {
+	offset := 0
+
+	for i := 0; ; i++ {
+		n := bytes.IndexByte(contents[offset:], '\n')
+		if n < 0 {
+			return 0, ErrGoObject
+		}
+		line := string(contents[offset : offset+n])
+		offset += n + 1
+
+		if i == 0 {
+			fields := strings.Fields(line)
+			if len(fields) < 5 || fields[0] != "go" || fields[1] != "object" {
+				return 0, ErrGoObject
+			}
+
+			header.GOOS = fields[2]
+			header.GOARCH = fields[3]
+			header.GoVersion = fields[4]
+			header.Fields = fields[5:]
+			continue
+		}
+
+		switch {
+		case line == end:
+			return offset, nil
+		case line == "main":
+			header.Main = true
+		case strings.HasPrefix(line, "build id "):
+			id, err := strconv.Unquote(line[len("build id "):])
+			if err != nil {
+				return 0, ErrGoObject
+			}
+
+			header.BuildID = id
+		}
+	}
+}
+
+// goobjImports returns the import paths from the autolib block of the Go
+// object data in obj.
+func goobjImports(obj []byte) ([]string, error) {
+	// Magic, fingerprint, flags and the autolib and pkgidx block offsets.
+	if len(obj) < 28 || !bytes.HasPrefix(obj, goobjMagic) {
+		return nil, ErrGoObject
+	}
+
+	start := binary.LittleEndian.Uint32(obj[20:])
+	end := binary.LittleEndian.Uint32(obj[24:])
+	if start > end || int64(end) > int64(len(obj)) {
+		return nil, ErrGoObject
+	}
+
+	// Each entry is a string reference followed by a fingerprint.
+	imports := make([]string, 0)
+	for i := start; i+16 <= end; i += 16 {
+		name, ok := goobjString(obj, obj[i:])
+		if !ok {
+			return nil, ErrGoObject
+		}
+
+		imports = append(imports, name)
+	}
+
+	return imports, nil
+}
+
+// goobjSymbols returns the names of the symbols defined by the Go _go_.o
+// entry contents. Hashed symbols, and local or duplicate ok symbols aren't
+// unique definitions so they're left out.
+func goobjSymbols(name string, contents []byte) ([]string, error) {
+	var header GoHeader
+	offset, err := parseGoHeader(&header, contents, "!")
+	if err != nil {
+		return nil, err
+	}
+
+	obj := contents[offset:]
+	if len(obj) < goobjBlocks+4*(goobjNonpkgdef+2) || !bytes.HasPrefix(obj, goobjMagic) {
+		return nil, ErrGoObject
+	}
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, block := range []int{goobjSymdef, goobjNonpkgdef} {
+		start := binary.LittleEndian.Uint32(obj[goobjBlocks+4*block:])
+		end := binary.LittleEndian.Uint32(obj[goobjBlocks+4*(block+1):])
+		if start > end || int64(end) > int64(len(obj)) {
+			return nil, ErrGoObject
+		}
+
+		for i := start; i+goobjSymSize <= end; i += goobjSymSize {
+			sym, ok := goobjString(obj, obj[i:])
+			if !ok {
+				return nil, ErrGoObject
+			}
+
+			if obj[i+11]&(goobjFlagDupok|goobjFlagLocal) != 0 || sym == "" || seen[sym] {
+				continue
+			}
+
+			seen[sym] = true
+			names = append(names, sym)
+		}
+	}
+
+	return names, nil
+}
+
+// goobjString returns the string referenced by the length and offset at the
+// start of ref.
+func goobjString(obj, ref []byte) (string, bool) {
+	length := int64(binary.LittleEndian.Uint32(ref))
+	offset := int64(binary.LittleEndian.Uint32(ref[4:]))
+	if offset+length > int64(len(obj)) {
+		return "", false
+	}
+
+	return string(obj[offset : offset+length]), true
+}
@@ -0,0 +1,182 @@
+package ar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGoMetadata(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "go_test.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	// The metadata is skipped by default.
+	arReader := NewReader(in)
+	header, err := arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || header.Name != "_go_.o" {
+		t.Fatal("Reader should skip the __.PKGDEF entry by default.")
+	}
+
+	_, err = in.Seek(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	arReader = NewReaderOptions(in, ReaderOptions{GoMetadata: true})
+
+	header, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || header.Name != "__.PKGDEF" {
+		t.Fatal("Reader should return the __.PKGDEF entry.")
+	}
+
+	pkgdef, err := ParsePkgDef(arReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkgdef.GoVersion == "" || pkgdef.GOOS != "linux" || pkgdef.GOARCH != "amd64" {
+		t.Error("Object line isn't what it should be.")
+	}
+	if pkgdef.BuildID != "abc/def" {
+		t.Error("Build ID isn't what it should be.")
+	}
+	if pkgdef.Main {
+		t.Error("Package shouldn't be a main package.")
+	}
+	if len(pkgdef.Export) == 0 || pkgdef.Export[0] != 'u' {
+		t.Error("Export data isn't what it should be.")
+	}
+	if !bytes.Contains(pkgdef.Export, []byte("example.com/hello")) {
+		t.Error("Export data should contain the package path.")
+	}
+	if pkgdef.ExportOffset+int64(len(pkgdef.Export))+4 != header.Size {
+		t.Error("Export data should end before the $$ marker.")
+	}
+
+	header, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || header.Name != "_go_.o" {
+		t.Fatal("Reader should return the _go_.o entry.")
+	}
+
+	object, err := ParseGoObject(arReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.BuildID != pkgdef.BuildID {
+		t.Error("Object build ID should match the __.PKGDEF entry.")
+	}
+	if len(object.Imports) != 1 || object.Imports[0] != "strings" {
+		t.Error("Imports aren't what they should be.")
+	}
+}
+
+func TestInvalidGoMetadata(t *testing.T) {
+	invalid := []string{
+		"",
+		"!<arch>\n",
+		"go object linux\n\n$$B\n\n$$\n",
+		"go object linux amd64 go1.22.0\n\n$$B\n",
+		"go object linux amd64 go1.22.0\nbuild id abc\n\n$$B\n\n$$\n",
+	}
+
+	for _, contents := range invalid {
+		_, err := ParsePkgDef(strings.NewReader(contents))
+		if err != ErrGoObject {
+			t.Errorf("Parsing %q should return ErrGoObject.", contents)
+		}
+	}
+
+	_, err := ParseGoObject(strings.NewReader("go object linux amd64 go1.22.0\n\n!\n\x00go120ld"))
+	if err != ErrGoObject {
+		t.Error("Parsing truncated object data should return ErrGoObject.")
+	}
+}
+
+func TestGoSymbols(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "go_test.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	var out bytes.Buffer
+	var pkgdef []byte
+	arReader := NewReaderOptions(in, ReaderOptions{GoMetadata: true})
+	arWriter := NewWriter(&out)
+	for {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil {
+			break
+		}
+
+		contents, err := ioutil.ReadAll(arReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Name == "__.PKGDEF" {
+			pkgdef = contents
+		}
+
+		err = arWriter.WriteHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader = NewReaderOptions(&out, ReaderOptions{GoMetadata: true})
+	for {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil {
+			break
+		}
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil {
+		t.Fatal("Archive should have a symbol table.")
+	}
+
+	expected := []string{"example.com/hello.init", "example.com/hello.Hello", "example.com/hello..inittask", "example.com/hello.Greeting"}
+	if len(symbols.Symbols) != len(expected) {
+		t.Fatalf("Symbol table has %d symbols, expected %d.", len(symbols.Symbols), len(expected))
+	}
+	for i, sym := range symbols.Symbols {
+		if sym.Name != expected[i] || sym.Member != "_go_.o" {
+			t.Errorf("Symbol %+v should be %s defined by _go_.o.", sym, expected[i])
+		}
+	}
+
+	_, err = goobjSymbols("__.PKGDEF", pkgdef)
+	if err != ErrGoObject {
+		t.Error("Package definitions shouldn't define symbols.")
+	}
+}
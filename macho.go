@@ -0,0 +1,93 @@
+package ar
+
+import (
+	"debug/macho"
+	"io"
+)
+
+// machoMagics identifies Mach-O object files of either byte order, and fat
+// files containing an object for each architecture.
+var machoMagics = [][]byte{
+	{0xfe, 0xed, 0xfa, 0xce}, {0xfe, 0xed, 0xfa, 0xcf},
+	{0xce, 0xfa, 0xed, 0xfe}, {0xcf, 0xfa, 0xed, 0xfe},
+	machoFatMagic,
+}
+
+// machoFatMagic identifies fat files, shared with Java class files.
+var machoFatMagic = []byte{0xca, 0xfe, 0xba, 0xbe}
+
+// Bits of the Mach-O nlist type field, from mach-o/nlist.h.
+const (
+	machoStab = 0xe0 // N_STAB, debugging entries.
+	machoType = 0x0e // N_TYPE, the symbol type mask.
+	machoExt  = 0x01 // N_EXT, external symbols.
+	machoUndf = 0x00 // N_UNDF, undefined and common symbols.
+)
+
+// machoSymbols returns the names of the external symbols defined by the
+// Mach-O object read from r, the same symbols Apple's ranlib adds to the
+// archive index. Common symbols are left out like ranlib does without -c.
+// Fat files return ErrUnsupportedObject, since the linker only reads archive
+// members for a single architecture. They should be split with lipo and
+// archived separately for each architecture, or indexed for one with
+// machoFatSymbols.
+func machoSymbols(r io.ReaderAt) ([]string, error) {
+	fat, err := macho.NewFatFile(r)
+	if err == nil {
+		fat.Close()
+		return nil, ErrUnsupportedObject
+	}
+	if err != macho.ErrNotFat {
+		return nil, err
+	}
+
+	file, err := macho.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return machoFileSymbols(file), nil
+}
+
+// machoFatSymbols returns the names of the external symbols defined by the
+// object for cpu in the fat file read from r. ErrUnsupportedObject is
+// returned if there's no object for it.
+func machoFatSymbols(r io.ReaderAt, cpu macho.Cpu) ([]string, error) {
+	fat, err := macho.NewFatFile(r)
+	if err != nil {
+		return nil, err
+	}
+	defer fat.Close()
+
+	for _, arch := range fat.Arches {
+		if arch.Cpu == cpu {
+			return machoFileSymbols(arch.File), nil
+		}
+	}
+
+	return nil, ErrUnsupportedObject
+}
+
+// machoFileSymbols returns the names of the external symbols defined by the
+// Mach-O file.
+func machoFileSymbols(file *macho.File) []string {
+	names := make([]string, 0)
+	if file.Symtab == nil {
+		return names
+	}
+
+	for _, sym := range file.Symtab.Syms {
+		if sym.Type&machoStab != 0 || sym.Type&machoExt == 0 {
+			continue
+		}
+
+		if sym.Type&machoType == machoUndf || sym.Name == "" {
+			continue
+		}
+
+		names = append(names, sym.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,161 @@
+package ar
+
+import (
+	"bytes"
+	"debug/macho"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMachOSymbols(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "macho.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	names, err := machoSymbols(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"_counter", "_hello", "_hidden_value"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Symbols are %v, expected %v.", names, expected)
+	}
+
+	fat, err := os.Open(filepath.Join("testdata", "macho_fat.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fat.Close()
+
+	_, err = machoSymbols(fat)
+	if err != ErrUnsupportedObject {
+		t.Error("Fat files should return ErrUnsupportedObject.")
+	}
+
+	_, err = machoSymbols(bytes.NewReader([]byte("\xca\xfe\xba\xbe\x00\x03\x00\x34")))
+	if err == nil {
+		t.Error("Java class files shouldn't be parsed as fat files.")
+	}
+}
+
+func TestMachOBSDWrite(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatBSD})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "macho.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.WriteHeader(&Header{Name: "macho.o", Mode: 0644, Size: int64(len(contents))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = arWriter.Write(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 3 {
+		t.Fatal("Symbol table should contain the symbols from the object.")
+	}
+
+	expected := []string{"_counter", "_hello", "_hidden_value"}
+	for i, sym := range symbols.Symbols {
+		if sym.Name != expected[i] {
+			t.Error("Symbol table should be sorted by name.")
+		}
+	}
+
+	if symbols.Lookup("_hello").Member != "macho.o" {
+		t.Error("Symbols should resolve to their defining entries.")
+	}
+}
+
+func TestMachOFatWrite(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "macho_fat.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(opts WriterOptions) (*bytes.Buffer, error) {
+		var out bytes.Buffer
+		arWriter, err := NewWriterOptions(&out, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = arWriter.WriteHeader(&Header{Name: "macho_fat.o", Mode: 0644, Size: int64(len(contents))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return &out, arWriter.Close()
+	}
+
+	var serr *SymbolError
+	_, err = write(WriterOptions{Format: FormatBSD})
+	if !errors.As(err, &serr) || serr.Name != "macho_fat.o" {
+		t.Errorf("Fat members should be rejected, got %v.", err)
+	}
+
+	_, err = write(WriterOptions{Format: FormatBSD, MachOCPU: macho.CpuPpc})
+	if !errors.As(err, &serr) {
+		t.Errorf("Fat members without the architecture should be rejected, got %v.", err)
+	}
+
+	// Other formats leave them out of the symbol table.
+	for _, format := range []Format{FormatGNU, FormatCommon} {
+		_, err = write(WriterOptions{Format: format})
+		if err != nil {
+			t.Errorf("Format %d shouldn't reject fat members, got %v.", format, err)
+		}
+	}
+
+	out, err := write(WriterOptions{Format: FormatBSD, MachOCPU: macho.CpuArm64})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0)
+	for _, sym := range arReader.Symbols().Symbols {
+		names = append(names, sym.Name)
+	}
+
+	expected := []string{"_arm64_only", "_counter", "_hello", "_hidden_value"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Symbols are %v, expected the arm64 object's %v.", names, expected)
+	}
+}
@@ -3,8 +3,10 @@ package ar
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -13,42 +15,173 @@ import (
 var (
 	ErrHeader       = errors.New("ar: invalid ar header")
 	ErrStringsEntry = errors.New("ar: entry name not in strings table")
+
+	ErrTableTooLarge   = errors.New("ar: tables exceed size limit")
+	ErrNameTooLong     = errors.New("ar: entry name exceeds length limit")
+	ErrTooManyMembers  = errors.New("ar: archive exceeds member limit")
+	ErrArchiveTooLarge = errors.New("ar: archive exceeds size limit")
 )
 
+// HeaderError records the position and field of an invalid header. It wraps
+// ErrHeader, ErrStringsEntry or the error for the limit exceeded, and the error
+// parsing the field if any.
+type HeaderError struct {
+	Offset int64  // Byte offset to the header in the archive.
+	Index  int    // Index of the header, counting table entries, or -1 for the archive header.
+	Field  string // Name of the invalid field from ar.h, e.g. "mode".
+	Value  []byte // Raw contents of the field.
+	Err    error  // ErrHeader, ErrStringsEntry or a limit error.
+	Cause  error  // Error parsing the field, e.g. a *strconv.NumError, or nil.
+}
+
+func (e *HeaderError) Error() string {
+	pos := fmt.Sprintf("header %d at offset %d", e.Index, e.Offset)
+	if e.Index < 0 {
+		pos = "archive header"
+	}
+
+	msg := fmt.Sprintf("%v: %s: %s field %q", e.Err, pos, e.Field, e.Value)
+	if e.Cause != nil {
+		msg += ": " + e.Cause.Error()
+	}
+
+	return msg
+}
+
+// headerPos is the position of a header, see HeaderError.
+type headerPos struct {
+	offset int64
+	index  int
+}
+
+// fieldError creates the error for an invalid field of the header.
+func (pos headerPos) fieldError(field string, value []byte, sentinel, cause error) *HeaderError {
+	return &HeaderError{
+		Offset: pos.offset,
+		Index:  pos.index,
+		Field:  field,
+		Value:  append([]byte(nil), value...),
+		Err:    sentinel,
+		Cause:  cause,
+	}
+}
+
+// Unwrap returns the sentinel error and the cause, so both can be matched by
+// errors.Is and errors.As.
+func (e *HeaderError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Err}
+	}
+
+	return []error{e.Err, e.Cause}
+}
+
 // Reader provides sequential access to an ar archive. The Next method
 // advances to the next file entry, which afterwards can be treated as an
 // io.Reader.
 type Reader struct {
-	reader  io.Reader
+	reader  *countReader
 	strings map[int64]string // Contains the GNU strings table(key=offset).
+	symbols *SymbolTable     // Contains the parsed symbol table.
 	ur      int64            // Unread bytes for the current entry.
 	pad     bool             // If the entry contains the padding byte.
 	magic   bool             // Indicates if magic number has been read.
+	thin    bool             // If the archive is a GNU thin archive.
+	gnu     bool             // If GNU tables or / terminated names were read.
+	format  Format           // Variant detected from the entries read.
+	index   int              // Index of the next header, counting table entries.
+	opts    ReaderOptions
+
+	warnings  []*HeaderError // Invalid fields ignored while reading the current header.
+	tableSize int64          // Combined size of the tables read.
+	members   int            // Number of file entries returned.
+
+	big        *bigFileHeader   // Contains the AIX big archive header.
+	bigNext    int64            // Offset to the next AIX big archive member.
+	bigMembers map[int64]string // Contains the AIX member names(key=offset).
+}
+
+// ReaderOptions configures how a Reader reads an archive.
+type ReaderOptions struct {
+	// GoMetadata returns the Go toolchain __.PKGDEF and __.GOSYMDEF entries
+	// from Next instead of skipping them, see ParsePkgDef.
+	GoMetadata bool
+
+	// Lenient defaults invalid date, uid, gid and mode fields to zero instead
+	// of failing, recording them as warnings, see Reader.Warnings. Blank uid,
+	// gid and mode fields are always read as zero, but they're only recorded
+	// when lenient. Invalid names, sizes and trailers are still errors.
+	Lenient bool
+
+	// MaxTableSize limits the combined size of the strings and symbol tables,
+	// which are read into memory. Zero means no limit.
+	MaxTableSize int64
+
+	// MaxNameLength limits the length of BSD and AIX names, which follow the
+	// header. Zero means no limit.
+	MaxNameLength int64
+
+	// MaxMembers limits the number of file entries returned by Next. Zero
+	// means no limit.
+	MaxMembers int
+
+	// MaxTotalSize limits the number of bytes of the archive read, including
+	// the skipped contents of entries. Zero means no limit.
+	MaxTotalSize int64
 }
 
 // NewReader creates a Reader reading from r.
 func NewReader(r io.Reader) *Reader {
-	return &Reader{reader: r, strings: make(map[int64]string)}
+	return NewReaderOptions(r, ReaderOptions{})
+}
+
+// NewReaderOptions creates a Reader reading from r configured with opts.
+func NewReaderOptions(r io.Reader, opts ReaderOptions) *Reader {
+	return &Reader{
+		reader:  &countReader{reader: r},
+		strings: make(map[int64]string),
+		opts:    opts,
+	}
 }
 
 // Next advances to the next file entry. A nil, nil return indicates there
 // are no entries left to read.
 func (arr *Reader) Next() (*Header, error) {
+	arr.warnings = nil
+
+	// Loop rather than recurse, so skipped entries can't grow the stack.
+	for {
+		header, skip, err := arr.readHeader()
+		if err != nil || !skip {
+			return header, err
+		}
+	}
+}
+
+// readHeader reads the next header, parsing the table entries. It reports if
+// the entry is skipped, either a table or Go metadata.
+func (arr *Reader) readHeader() (*Header, bool, error) {
 	var err error
 
 	if !arr.magic {
 		err = arr.readMagic()
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 	}
 
 	err = arr.skipUnread()
 	if err != nil {
-		return nil, err
+		return nil, false, err
+	}
+
+	if arr.big != nil {
+		header, err := arr.nextBig()
+		return header, false, err
 	}
 
 	header := new(Header)
+	offset := arr.reader.n
 	hdr := make([]byte, 60)
 
 	_, err = io.ReadFull(arr.reader, hdr)
@@ -57,8 +190,12 @@ func (arr *Reader) Next() (*Header, error) {
 			err = nil
 		}
 
-		return nil, err
+		return nil, false, err
 	}
+	index := arr.index
+	arr.index++
+
+	pos := headerPos{offset: offset, index: index}
 
 	nameField := arr.trimPad(hdr[:16])
 	timeField := arr.trimPad(hdr[16:28])
@@ -68,32 +205,48 @@ func (arr *Reader) Next() (*Header, error) {
 	sizeField := arr.trimPad(hdr[48:58])
 	trailerField := arr.trimPad(hdr[58:60])
 	if trailerField != "`\n" {
-		return nil, ErrHeader
+		return nil, false, pos.fieldError("fmag", hdr[58:60], ErrHeader, nil)
 	}
 
 	// Convert timestamp.
 	timeInt, err := strconv.ParseInt(timeField, 10, 64)
 	if err != nil && nameField != "//" {
-		return nil, err
+		err = arr.warn(pos.fieldError("date", hdr[16:28], ErrHeader, err))
+		if err != nil {
+			return nil, false, err
+		}
+		timeInt = 0
 	}
 	header.ModTime = time.Unix(timeInt, 0)
 
-	// Convert uid/gid.
+	// Convert uid/gid, Microsoft tools leave them blank.
 	uidInt, err := strconv.Atoi(uidField)
 	if err != nil && nameField != "//" {
-		return nil, err
+		err = arr.warnNumeric(nameField, pos.fieldError("uid", hdr[28:34], ErrHeader, err))
+		if err != nil {
+			return nil, false, err
+		}
+		uidInt = 0
 	}
 	gidInt, err := strconv.Atoi(gidField)
 	if err != nil && nameField != "//" {
-		return nil, err
+		err = arr.warnNumeric(nameField, pos.fieldError("gid", hdr[34:40], ErrHeader, err))
+		if err != nil {
+			return nil, false, err
+		}
+		gidInt = 0
 	}
 	header.Uid = uidInt
 	header.Gid = gidInt
 
-	// Convert mode.
+	// Convert mode, it's also blank for Microsoft linker members.
 	modeInt, err := strconv.ParseInt(modeField, 8, 64)
 	if err != nil && nameField != "//" {
-		return nil, err
+		err = arr.warnNumeric(nameField, pos.fieldError("mode", hdr[40:48], ErrHeader, err))
+		if err != nil {
+			return nil, false, err
+		}
+		modeInt = 0
 	}
 	header.Mode = modeInt
 
@@ -102,36 +255,45 @@ func (arr *Reader) Next() (*Header, error) {
 	nameSize := int64(-1)
 	if len(nameField) > 3 && nameField[:3] == "#1/" {
 		extendedFormat = "bsd"
+		arr.format = FormatBSD
 		nameSize, err = strconv.ParseInt(nameField[3:], 10, 64)
 		if err != nil {
-			return nil, err
+			return nil, false, pos.fieldError("name", hdr[:16], ErrHeader, err)
 		}
 	}
-	if len(nameField) > 1 && nameField[0] == '/' && nameField != "//" {
+	if len(nameField) > 1 && nameField[0] == '/' && nameField != "//" &&
+		nameField != "/SYM64/" {
 		extendedFormat = "gnu"
+		arr.gnu = true
 		nameSize, err = strconv.ParseInt(nameField[1:], 10, 64)
 		if err != nil {
-			return nil, err
+			return nil, false, pos.fieldError("name", hdr[:16], ErrHeader, err)
 		}
 	}
 
 	// Convert and retrieve the entry size.
 	sizeInt, err := strconv.ParseInt(sizeField, 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, false, pos.fieldError("size", hdr[48:58], ErrHeader, err)
 	}
 	header.Size = sizeInt
 	if extendedFormat == "bsd" {
 		header.Size -= nameSize
 	}
 
-	// Retrieve the name.
+	// Retrieve the name, the BSD name is included in the size.
 	header.Name = nameField
 	if extendedFormat == "bsd" {
-		name := make([]byte, nameSize)
-		_, err = io.ReadFull(arr.reader, name)
+		if nameSize < 0 || header.Size < 0 {
+			return nil, false, pos.fieldError("name", hdr[:16], ErrHeader, nil)
+		}
+		if arr.opts.MaxNameLength > 0 && nameSize > arr.opts.MaxNameLength {
+			return nil, false, pos.fieldError("name", hdr[:16], ErrNameTooLong, nil)
+		}
+
+		name, err := readAll(arr.reader, nameSize)
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
 
 		header.Name = arr.trimPad(name)
@@ -140,42 +302,155 @@ func (arr *Reader) Next() (*Header, error) {
 	if extendedFormat == "gnu" {
 		name, ok := arr.strings[nameSize]
 		if !ok {
-			return nil, ErrStringsEntry
+			return nil, false, pos.fieldError("name", hdr[:16], ErrStringsEntry, nil)
 		}
 
 		header.Name = name
 	}
 
-	// Set unread and padding.
+	// Set unread and padding, thin archives only store the tables contents.
+	// The padding follows the BSD name too, so it depends on the size field.
 	arr.ur = header.Size
-	if header.Size%2 == 0 {
+	if sizeInt%2 == 0 {
 		arr.pad = false
 	} else {
 		arr.pad = true
 	}
+	if arr.thin && header.Name != "/" && header.Name != "/SYM64/" && header.Name != "//" {
+		arr.ur = 0
+		arr.pad = false
+	}
+	if arr.opts.MaxTotalSize > 0 && arr.reader.n+arr.ur > arr.opts.MaxTotalSize {
+		return nil, false, pos.fieldError("size", hdr[48:58], ErrArchiveTooLarge, nil)
+	}
+
+	symbolTable := header.Name == "/" || header.Name == "/SYM64/" ||
+		strings.HasPrefix(header.Name, "__.SYMDEF")
+	if header.Name == "//" || symbolTable {
+		arr.tableSize += arr.ur
+		if arr.opts.MaxTableSize > 0 && arr.tableSize > arr.opts.MaxTableSize {
+			return nil, false, pos.fieldError("size", hdr[48:58], ErrTableTooLarge, nil)
+		}
+	}
+
+	if header.Name == "//" || header.Name == "/" || header.Name == "/SYM64/" {
+		arr.gnu = true
+	}
 
 	// Parse and store the strings table.
 	if header.Name == "//" {
 		err = arr.parseStringsTable(header)
 		if err != nil {
-			return nil, err
+			return nil, false, err
+		}
+
+		return nil, true, nil
+	}
+
+	// Parse and store the symbols table.
+	if symbolTable {
+		err = arr.parseSymbolTable(header)
+		if err != nil {
+			return nil, false, err
 		}
 
-		return arr.Next()
+		return nil, true, nil
+	}
+
+	// Skip Go metadata unless requested.
+	if !arr.opts.GoMetadata && (header.Name == "__.PKGDEF" || header.Name == "__.GOSYMDEF") {
+		return nil, true, nil
 	}
 
-	// Skip symbols table.
-	if header.Name == "/" || strings.Contains(header.Name, "__.SYMDEF") ||
-		header.Name == "__.PKGDEF" || header.Name == "__.GOSYMDEF" {
-		return arr.Next()
+	if header.Name == "" {
+		return nil, false, pos.fieldError("name", hdr[:16], ErrHeader, nil)
 	}
 
 	// Clean up GNU name.
 	if header.Name[len(header.Name)-1] == '/' {
 		header.Name = header.Name[:len(header.Name)-1]
+		arr.gnu = true
+	}
+
+	if arr.symbols != nil {
+		arr.symbols.resolve(offset, header.Name)
+	}
+
+	err = arr.countMember()
+	if err != nil {
+		return nil, false, err
+	}
+
+	return header, false, nil
+}
+
+// Thin reports whether the archive is a GNU thin archive. The file entries of
+// thin archives have no contents, instead their names are paths to external
+// files, see ThinPath. Thin is only valid after the first call to Next.
+func (arr *Reader) Thin() bool {
+	return arr.thin
+}
+
+// Format returns the variant of the archive, detected from the entries read
+// so far. Archives without any BSD entries or a second linker member are
+// reported as FormatGNU, or FormatCommon if there are no GNU tables or /
+// terminated names either, like Debian packages.
+func (arr *Reader) Format() Format {
+	if arr.format == FormatGNU && !arr.gnu {
+		return FormatCommon
+	}
+
+	return arr.format
+}
+
+// Symbols returns the archive symbol table, or nil if no symbol table has
+// been read. Symbols only have their member set once the member is read.
+func (arr *Reader) Symbols() *SymbolTable {
+	return arr.symbols
+}
+
+// Warnings returns the invalid fields defaulted to zero while reading the
+// current header, including any table entries skipped to reach it. It's
+// always empty unless the Reader is Lenient.
+func (arr *Reader) Warnings() []*HeaderError {
+	return arr.warnings
+}
+
+// warn records herr as a warning if the Reader is Lenient, otherwise herr is
+// returned.
+func (arr *Reader) warn(herr *HeaderError) error {
+	if !arr.opts.Lenient {
+		return herr
+	}
+
+	arr.warnings = append(arr.warnings, herr)
+	return nil
+}
+
+// warnNumeric is like warn for the uid, gid and mode fields of the header
+// named name. Blank fields are read as zero without failing, since Microsoft
+// tools leave them blank, but they're still recorded as warnings if the
+// Reader is Lenient unless they're in a linker member.
+func (arr *Reader) warnNumeric(name string, herr *HeaderError) error {
+	if len(bytes.TrimSpace(herr.Value)) > 0 {
+		return arr.warn(herr)
+	}
+
+	if arr.opts.Lenient && name != "/" {
+		arr.warnings = append(arr.warnings, herr)
+	}
+
+	return nil
+}
+
+// countMember counts a file entry returned by Next, enforcing MaxMembers.
+func (arr *Reader) countMember() error {
+	arr.members++
+	if arr.opts.MaxMembers > 0 && arr.members > arr.opts.MaxMembers {
+		return ErrTooManyMembers
 	}
 
-	return header, nil
+	return nil
 }
 
 // Read reads from the current entry. It returns 0, io.EOF when the end is
@@ -205,12 +480,13 @@ func (arr *Reader) skipUnread() error {
 	if arr.pad {
 		unread++
 	}
+	arr.ur = 0
+	arr.pad = false
 
-	_, err := io.CopyN(ioutil.Discard, arr.reader, unread)
-	return err
+	return arr.reader.skip(unread)
 }
 
-// readMagic reads the magic number.
+// readMagic reads the magic number for regular, thin and AIX big archives.
 func (arr *Reader) readMagic() error {
 	magic := make([]byte, 8)
 
@@ -219,8 +495,19 @@ func (arr *Reader) readMagic() error {
 		return err
 	}
 
-	if string(magic) != "!<arch>\n" {
-		return ErrHeader
+	switch string(magic) {
+	case "!<arch>\n":
+	case "!<thin>\n":
+		arr.thin = true
+		arr.format = FormatGNUThin
+	case "<bigaf>\n":
+		arr.format = FormatAIXBig
+		err = arr.readBigFileHeader()
+		if err != nil {
+			return err
+		}
+	default:
+		return &HeaderError{Index: -1, Field: "magic", Value: magic, Err: ErrHeader}
 	}
 
 	arr.magic = true
@@ -232,19 +519,51 @@ func (arr *Reader) trimPad(field []byte) string {
 	return string(bytes.TrimRight(field, " \u0000"))
 }
 
+// parseSymbolTable gets the GNU or BSD symbol table from a file entry, in
+// either its 32 or 64 bit form. A second / entry is the COFF second linker
+// member, which replaces the table from the first.
+func (arr *Reader) parseSymbolTable(header *Header) error {
+	table, err := readAll(arr, header.Size)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case header.Name == "/" && arr.symbols != nil:
+		arr.format = FormatCOFF
+		arr.symbols, err = parseCOFFSymbolTable(table)
+	case header.Name == "/":
+		arr.symbols, err = parseGNUSymbolTable(table, 4)
+	case header.Name == "/SYM64/":
+		arr.symbols, err = parseGNUSymbolTable(table, 8)
+	case strings.HasPrefix(header.Name, "__.SYMDEF_64"):
+		arr.format = FormatBSD
+		arr.symbols, err = parseBSDSymbolTable(table, 8)
+	default:
+		arr.format = FormatBSD
+		arr.symbols, err = parseBSDSymbolTable(table, 4)
+	}
+
+	return err
+}
+
 // parseStringsTable gets the GNU strings table from a file entry.
 func (arr *Reader) parseStringsTable(header *Header) error {
-	strings := make([]byte, header.Size)
-	_, err := io.ReadFull(arr, strings)
+	strings, err := readAll(arr, header.Size)
 	if err != nil {
 		return err
 	}
 	offset := 0
 	name := make([]byte, 0)
 
+	// GNU names end with /\n, COFF names are null terminated.
 	for i, c := range strings {
-		if c == '\n' {
-			arr.strings[int64(offset)] = string(bytes.TrimRight(name, "/"))
+		if c == '\n' || c == 0 {
+			if c == '\n' {
+				name = bytes.TrimRight(name, "/")
+			}
+
+			arr.strings[int64(offset)] = string(name)
 			name = make([]byte, 0)
 			offset = i + 1
 			continue
@@ -255,3 +574,77 @@ func (arr *Reader) parseStringsTable(header *Header) error {
 
 	return nil
 }
+
+// ThinPath returns the path of the external file referenced by a thin archive
+// file entry. Relative names are resolved against the directory containing the
+// archive at archivePath.
+func ThinPath(archivePath string, header *Header) string {
+	name := filepath.FromSlash(header.Name)
+	if filepath.IsAbs(name) {
+		return name
+	}
+
+	return filepath.Join(filepath.Dir(archivePath), name)
+}
+
+// readAll reads n bytes from r. The buffer grows as it's read rather than
+// being allocated from n, which comes from an untrusted size field.
+func readAll(r io.Reader, n int64) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, n))
+	if err == nil && int64(len(b)) < n {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return b, err
+}
+
+// countReader counts the bytes read from reader.
+type countReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (cr *countReader) Read(b []byte) (int, error) {
+	n, err := cr.reader.Read(b)
+	cr.n += int64(n)
+
+	return n, err
+}
+
+// skip discards n bytes, seeking past them if the reader supports it. Since
+// seeking past the end succeeds, the position is checked against the end so
+// truncated archives return io.ErrUnexpectedEOF either way.
+func (cr *countReader) skip(n int64) error {
+	if n == 0 {
+		return nil
+	}
+
+	seeker, ok := cr.reader.(io.Seeker)
+	if ok {
+		pos, err := seeker.Seek(n, io.SeekCurrent)
+		if err == nil {
+			end, err := seeker.Seek(0, io.SeekEnd)
+			if err != nil {
+				return err
+			}
+			if pos > end {
+				return io.ErrUnexpectedEOF
+			}
+
+			_, err = seeker.Seek(pos, io.SeekStart)
+			if err != nil {
+				return err
+			}
+
+			cr.n += n
+			return nil
+		}
+	}
+
+	_, err := io.CopyN(ioutil.Discard, cr, n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,135 @@
+package ar
+
+import (
+	"errors"
+	"io"
+)
+
+var (
+	ErrMemberNotFound = errors.New("ar: member not found")
+	ErrThinMember     = errors.New("ar: member contents not stored in thin archive")
+)
+
+// ReaderAt provides random access to the file entries of an ar archive. The
+// headers are indexed once when opened, and the contents of each entry can be
+// read concurrently using separate section readers.
+type ReaderAt struct {
+	reader   io.ReaderAt
+	members  []*Header
+	offsets  map[*Header]int64          // Contains the offset to each entries contents.
+	warnings map[*Header][]*HeaderError // Contains the warnings for lenient reads.
+	names    map[string]*Header         // Contains the first entry for each name.
+	symbols  *SymbolTable
+	thin     bool
+	format   Format
+}
+
+// OpenReaderAt indexes the headers of the archive read from r, which is size
+// bytes long.
+func OpenReaderAt(r io.ReaderAt, size int64) (*ReaderAt, error) {
+	return OpenReaderAtOptions(r, size, ReaderOptions{})
+}
+
+// OpenReaderAtOptions is like OpenReaderAt, reading the headers configured
+// with opts.
+func OpenReaderAtOptions(r io.ReaderAt, size int64, opts ReaderOptions) (*ReaderAt, error) {
+	ara := &ReaderAt{
+		reader:   r,
+		members:  make([]*Header, 0),
+		offsets:  make(map[*Header]int64),
+		warnings: make(map[*Header][]*HeaderError),
+		names:    make(map[string]*Header),
+	}
+	arr := NewReaderOptions(io.NewSectionReader(r, 0, size), opts)
+
+	for {
+		header, err := arr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			break
+		}
+
+		offset := arr.reader.n
+		if !arr.Thin() && offset+header.Size > size {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		ara.members = append(ara.members, header)
+		ara.offsets[header] = offset
+		if warnings := arr.Warnings(); len(warnings) > 0 {
+			ara.warnings[header] = warnings
+		}
+		if _, ok := ara.names[header.Name]; !ok {
+			ara.names[header.Name] = header
+		}
+	}
+	ara.symbols = arr.Symbols()
+	ara.thin = arr.Thin()
+	ara.format = arr.Format()
+
+	return ara, nil
+}
+
+// Symbols returns the archive symbol table, or nil if there isn't one.
+func (ara *ReaderAt) Symbols() *SymbolTable {
+	return ara.symbols
+}
+
+// Warnings returns the invalid fields defaulted to zero reading header, see
+// Reader.Warnings.
+func (ara *ReaderAt) Warnings(header *Header) []*HeaderError {
+	return ara.warnings[header]
+}
+
+// Members returns the headers for the file entries in archive order.
+func (ara *ReaderAt) Members() []*Header {
+	members := make([]*Header, len(ara.members))
+	copy(members, ara.members)
+
+	return members
+}
+
+// Lookup returns the header for the first file entry named name, or nil if
+// there isn't one.
+func (ara *ReaderAt) Lookup(name string) *Header {
+	return ara.names[name]
+}
+
+// Format returns the variant of the archive, see Reader.Format.
+func (ara *ReaderAt) Format() Format {
+	return ara.format
+}
+
+// Thin reports whether the archive is a GNU thin archive, see Reader.Thin.
+func (ara *ReaderAt) Thin() bool {
+	return ara.thin
+}
+
+// Section returns a reader for the contents of the file entry for header,
+// which must be one of the headers returned by Members or Lookup. Nil is
+// returned if header isn't from the archive or the archive is thin.
+func (ara *ReaderAt) Section(header *Header) *io.SectionReader {
+	offset, ok := ara.offsets[header]
+	if !ok || ara.thin {
+		return nil
+	}
+
+	return io.NewSectionReader(ara.reader, offset, header.Size)
+}
+
+// Open returns a reader for the contents of the first file entry named name.
+// ErrMemberNotFound is returned if there's no entry with the name, and
+// ErrThinMember if the archive is thin.
+func (ara *ReaderAt) Open(name string) (*io.SectionReader, error) {
+	header := ara.Lookup(name)
+	if header == nil {
+		return nil, ErrMemberNotFound
+	}
+	if ara.thin {
+		return nil, ErrThinMember
+	}
+
+	return ara.Section(header), nil
+}
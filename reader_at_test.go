@@ -0,0 +1,87 @@
+package ar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestReaderAt(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "gnu_test.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader, err := OpenReaderAt(in, stat.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := arReader.Members()
+	if len(members) != 1 || members[0].Name != "exit.o" {
+		t.Fatal("Reader should find only the exit.o entry.")
+	}
+
+	object, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read the entry concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			section, err := arReader.Open("exit.o")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			contents, err := ioutil.ReadAll(section)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !bytes.Equal(contents, object) {
+				t.Error("Entry contents don't match exit.o.")
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, err = arReader.Open("missing.o")
+	if err != ErrMemberNotFound {
+		t.Error("Open should have returned ErrMemberNotFound but didn't.")
+	}
+}
+
+func TestReaderAtInvalidSize(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "invalid_size.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = OpenReaderAt(in, stat.Size())
+	if err == nil {
+		t.Error("OpenReaderAt should fail if an entry is truncated.")
+	}
+}
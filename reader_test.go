@@ -1,10 +1,15 @@
 package ar
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -84,7 +89,7 @@ func TestGNUInvalidStrings(t *testing.T) {
 	arReader := NewReader(in)
 
 	_, err = arReader.Next()
-	if err != nil && err != ErrStringsEntry {
+	if err != nil && !errors.Is(err, ErrStringsEntry) {
 		t.Fatal(err)
 	}
 
@@ -174,6 +179,50 @@ func TestInvalidSize(t *testing.T) {
 	}
 }
 
+func TestTruncatedSkip(t *testing.T) {
+	archive, err := ioutil.ReadFile(filepath.Join("testdata", "gnu_test.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive = archive[:len(archive)-2]
+
+	path := filepath.Join(t.TempDir(), "truncated.a")
+	err = ioutil.WriteFile(path, archive, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	readers := map[string]io.Reader{
+		"file":    file,
+		"bytes":   bytes.NewReader(archive),
+		"strings": strings.NewReader(string(archive)),
+		"reader":  struct{ io.Reader }{bytes.NewReader(archive)},
+	}
+	for name, r := range readers {
+		arReader := NewReader(r)
+
+		// Skip the contents of every entry without reading them.
+		for {
+			header, err := arReader.Next()
+			if err != nil {
+				if err != io.ErrUnexpectedEOF {
+					t.Errorf("Next with the %s reader returned %v, expected ErrUnexpectedEOF.", name, err)
+				}
+				break
+			}
+			if header == nil {
+				t.Errorf("Next with the %s reader should fail for the truncated entry.", name)
+				break
+			}
+		}
+	}
+}
+
 func TestInvalidFormat(t *testing.T) {
 	in, err := os.Open(filepath.Join("testdata", "out", "gnu_exit.o"))
 	if err != nil {
@@ -183,7 +232,7 @@ func TestInvalidFormat(t *testing.T) {
 	arReader := NewReader(in)
 
 	_, err = arReader.Next()
-	if err != nil && err != ErrHeader {
+	if err != nil && !errors.Is(err, ErrHeader) {
 		t.Fatal(err)
 	}
 
@@ -201,7 +250,7 @@ func TestInvalidHeader(t *testing.T) {
 	arReader := NewReader(in)
 
 	_, err = arReader.Next()
-	if err != nil && err != ErrHeader {
+	if err != nil && !errors.Is(err, ErrHeader) {
 		t.Fatal(err)
 	}
 
@@ -209,3 +258,231 @@ func TestInvalidHeader(t *testing.T) {
 		t.Error("Next should have returned ErrHeader but didn't.")
 	}
 }
+
+func TestThinRead(t *testing.T) {
+	archivePath := filepath.Join("testdata", "thin_test.a")
+	in, err := os.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	arReader := NewReader(in)
+
+	names := make([]string, 0)
+	for {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil {
+			break
+		}
+		names = append(names, header.Name)
+
+		n, err := arReader.Read(make([]byte, 1))
+		if n != 0 || err != io.EOF {
+			t.Error("Thin entries shouldn't have any contents.")
+		}
+
+		info, err := os.Stat(ThinPath(archivePath, header))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != header.Size {
+			t.Error("Referenced file size doesn't match header.")
+		}
+	}
+
+	if !arReader.Thin() {
+		t.Error("Reader should detect the thin archive.")
+	}
+
+	if len(names) != 2 || names[0] != "exit.o" || names[1] != "hello.o" {
+		t.Error("Reader should find both thin entries.")
+	}
+
+	if arReader.Symbols() == nil || arReader.Symbols().Lookup("world").Member != "hello.o" {
+		t.Error("Reader should parse the thin archive symbol table.")
+	}
+}
+
+func TestHeaderError(t *testing.T) {
+	var out bytes.Buffer
+	arWriter := NewWriter(&out)
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		err := arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write([]byte("ok"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the mode of the second entry.
+	archive := out.Bytes()
+	offset := bytes.Index(archive, []byte("b.txt/"))
+	copy(archive[offset+40:offset+48], "10x644  ")
+
+	arReader := NewReader(bytes.NewReader(archive))
+	_, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = arReader.Next()
+	if !errors.Is(err, ErrHeader) {
+		t.Fatal("Next should have returned an error wrapping ErrHeader.")
+	}
+
+	var headerErr *HeaderError
+	if !errors.As(err, &headerErr) {
+		t.Fatal("Next should have returned a *HeaderError.")
+	}
+	if headerErr.Offset != int64(offset) || headerErr.Index != 2 || headerErr.Field != "mode" {
+		t.Errorf("Header error position isn't what it should be: %v.", err)
+	}
+	if string(headerErr.Value) != "10x644  " {
+		t.Error("Header error value should be the raw field.")
+	}
+
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Error("Header error should wrap the parse error.")
+	}
+}
+
+func TestLenientRead(t *testing.T) {
+	header := func(name, date, uid, mode, size string) string {
+		return fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", name, date, uid, "0", mode, size)
+	}
+	archive := "!<arch>\n" +
+		header("a.o/", "1700000000x", "12a", "644", "2") + "ok" +
+		header("b.o/", "1700000000", "0", "100644", "2") + "ok"
+
+	_, err := NewReader(bytes.NewReader([]byte(archive))).Next()
+	if !errors.Is(err, ErrHeader) {
+		t.Fatal("Next should fail on invalid fields unless lenient.")
+	}
+
+	arReader := NewReaderOptions(bytes.NewReader([]byte(archive)), ReaderOptions{Lenient: true})
+	hdr, err := arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "a.o" || hdr.ModTime.Unix() != 0 || hdr.Uid != 0 || hdr.Mode != 0644 {
+		t.Error("Invalid fields should default to zero.")
+	}
+
+	warnings := arReader.Warnings()
+	if len(warnings) != 2 || warnings[0].Field != "date" || warnings[1].Field != "uid" {
+		t.Fatal("Reader should record a warning for each invalid field.")
+	}
+	if warnings[1].Index != 0 || warnings[1].Offset != 8 {
+		t.Error("Warning position isn't what it should be.")
+	}
+
+	hdr, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != "b.o" || hdr.ModTime.Unix() != 1700000000 {
+		t.Error("Valid header isn't what it should be.")
+	}
+	if len(arReader.Warnings()) != 0 {
+		t.Error("Warnings should be reset for each header.")
+	}
+
+	ara, err := OpenReaderAtOptions(bytes.NewReader([]byte(archive)), int64(len(archive)), ReaderOptions{Lenient: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ara.Warnings(ara.Lookup("a.o"))) != 2 || len(ara.Warnings(ara.Lookup("b.o"))) != 0 {
+		t.Error("ReaderAt should record the warnings for each header.")
+	}
+
+	// Framing errors still fail.
+	archive = "!<arch>\n" + header("a.o/", "0", "0", "644", "2x") + "ok"
+	_, err = NewReaderOptions(bytes.NewReader([]byte(archive)), ReaderOptions{Lenient: true}).Next()
+	if !errors.Is(err, ErrHeader) {
+		t.Error("Next should fail on an invalid size even if lenient.")
+	}
+}
+
+func TestReaderLimits(t *testing.T) {
+	header := func(name, size string) string {
+		return fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", name, "0", "0", "0", "644", size)
+	}
+
+	// The table size comes from the header, it shouldn't be allocated up front.
+	archive := "!<arch>\n" + header("//", "9999999999")
+	_, err := NewReader(strings.NewReader(archive)).Next()
+	if err != io.ErrUnexpectedEOF {
+		t.Error("Next should fail reading a truncated strings table.")
+	}
+
+	_, err = NewReaderOptions(strings.NewReader(archive), ReaderOptions{MaxTableSize: 1024}).Next()
+	if !errors.Is(err, ErrTableTooLarge) {
+		t.Error("Next should have returned ErrTableTooLarge.")
+	}
+
+	archive = "!<arch>\n" + header("#1/1000000", "1000000")
+	_, err = NewReaderOptions(strings.NewReader(archive), ReaderOptions{MaxNameLength: 1024}).Next()
+	if !errors.Is(err, ErrNameTooLong) {
+		t.Error("Next should have returned ErrNameTooLong.")
+	}
+
+	archive = "!<arch>\n" + header("#1/1000000", "10")
+	_, err = NewReader(strings.NewReader(archive)).Next()
+	if !errors.Is(err, ErrHeader) {
+		t.Error("Next should reject BSD names longer than the entry.")
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "gnu_test.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lib, err := ioutil.ReadFile(filepath.Join("testdata", "corpus", "llvm_dlltool.lib"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader := NewReaderOptions(bytes.NewReader(lib), ReaderOptions{MaxMembers: 1})
+	_, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = arReader.Next()
+	if err != ErrTooManyMembers {
+		t.Error("Next should have returned ErrTooManyMembers.")
+	}
+
+	arReader = NewReaderOptions(bytes.NewReader(contents), ReaderOptions{MaxTotalSize: 100})
+	_, err = arReader.Next()
+	if !errors.Is(err, ErrArchiveTooLarge) {
+		t.Error("Next should have returned ErrArchiveTooLarge.")
+	}
+}
+
+func TestSkippedEntries(t *testing.T) {
+	archive := "!<arch>\n" +
+		strings.Repeat(fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", "__.PKGDEF", "0", "0", "0", "644", "0"), 100000) +
+		fmt.Sprintf("%-16s%-12s%-6s%-6s%-8s%-10s`\n", "a.o/", "0", "0", "0", "644", "0")
+
+	header, err := NewReader(strings.NewReader(archive)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || header.Name != "a.o" {
+		t.Error("Next should skip every Go metadata entry.")
+	}
+}
@@ -0,0 +1,163 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrSymbolTable = errors.New("ar: invalid symbol table")
+)
+
+// Symbol is an entry in an archive symbol table.
+type Symbol struct {
+	Name   string // Name of the symbol.
+	Offset int64  // Byte offset to the header of the defining file entry.
+	Member string // Name of the defining file entry, once it's been read.
+}
+
+// SymbolTable is the index of symbols to the file entries that define them,
+// parsed from the GNU / or BSD __.SYMDEF entries.
+type SymbolTable struct {
+	Symbols []*Symbol           // Symbols in table order.
+	names   map[string]*Symbol  // Contains the first symbol for each name.
+	offsets map[int64][]*Symbol // Contains the symbols for each offset.
+}
+
+// newSymbolTable creates an empty SymbolTable.
+func newSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		Symbols: make([]*Symbol, 0),
+		names:   make(map[string]*Symbol),
+		offsets: make(map[int64][]*Symbol),
+	}
+}
+
+// Lookup returns the first symbol named name, or nil if there isn't one.
+func (st *SymbolTable) Lookup(name string) *Symbol {
+	return st.names[name]
+}
+
+// add appends a symbol to the table.
+func (st *SymbolTable) add(name string, offset int64) {
+	sym := &Symbol{Name: name, Offset: offset}
+
+	st.Symbols = append(st.Symbols, sym)
+	st.offsets[offset] = append(st.offsets[offset], sym)
+	if _, ok := st.names[name]; !ok {
+		st.names[name] = sym
+	}
+}
+
+// resolve sets the member name for the symbols defined by the file entry
+// whose header is at offset.
+func (st *SymbolTable) resolve(offset int64, member string) {
+	for _, sym := range st.offsets[offset] {
+		sym.Member = member
+	}
+}
+
+// parseGNUSymbolTable parses the GNU symbol table, a big endian count followed
+// by the member offsets and the null terminated symbol names. The count and
+// offsets are width bytes, 4 for the / table and 8 for the /SYM64/ table.
+func parseGNUSymbolTable(table []byte, width int64) (*SymbolTable, error) {
+	if int64(len(table)) < width {
+		return nil, ErrSymbolTable
+	}
+	count := getUint(binary.BigEndian, table, width)
+	table = table[width:]
+
+	if count < 0 || count > int64(len(table))/width {
+		return nil, ErrSymbolTable
+	}
+	offsets := table[:count*width]
+	names := table[count*width:]
+
+	st := newSymbolTable()
+	for i := int64(0); i < count; i++ {
+		end := bytes.IndexByte(names, 0)
+		if end < 0 {
+			return nil, ErrSymbolTable
+		}
+
+		st.add(string(names[:end]), getUint(binary.BigEndian, offsets[i*width:], width))
+		names = names[end+1:]
+	}
+
+	return st, nil
+}
+
+// parseBSDSymbolTable parses the BSD ranlib symbol table, the size of the
+// ranlib structs followed by the structs, and the size of the strings table
+// followed by the table. The byte order is the targets, so both are tried.
+// The sizes and struct fields are width bytes, 4 for __.SYMDEF and 8 for
+// __.SYMDEF_64.
+func parseBSDSymbolTable(table []byte, width int64) (*SymbolTable, error) {
+	st, err := parseRanlib(table, binary.LittleEndian, width)
+	if err != nil {
+		st, err = parseRanlib(table, binary.BigEndian, width)
+	}
+
+	return st, err
+}
+
+// parseRanlib parses the BSD ranlib symbol table using order.
+func parseRanlib(table []byte, order binary.ByteOrder, width int64) (*SymbolTable, error) {
+	if int64(len(table)) < width {
+		return nil, ErrSymbolTable
+	}
+	size := getUint(order, table, width)
+	table = table[width:]
+
+	if size < 0 || size%(width*2) != 0 || size > int64(len(table))-width {
+		return nil, ErrSymbolTable
+	}
+	ranlibs := table[:size]
+	strSize := getUint(order, table[size:], width)
+	table = table[size+width:]
+
+	if strSize < 0 || strSize > int64(len(table)) {
+		return nil, ErrSymbolTable
+	}
+	strs := table[:strSize]
+
+	st := newSymbolTable()
+	for i := int64(0); i < size; i += width * 2 {
+		strx := getUint(order, ranlibs[i:], width)
+		if strx < 0 || strx >= strSize {
+			return nil, ErrSymbolTable
+		}
+
+		name := strs[strx:]
+		end := bytes.IndexByte(name, 0)
+		if end >= 0 {
+			name = name[:end]
+		}
+
+		st.add(string(name), getUint(order, ranlibs[i+width:], width))
+	}
+
+	return st, nil
+}
+
+// getUint reads an unsigned integer that's width bytes long from b.
+func getUint(order binary.ByteOrder, b []byte, width int64) int64 {
+	if width == 8 {
+		return int64(order.Uint64(b))
+	}
+
+	return int64(order.Uint32(b))
+}
+
+// putUint appends an unsigned integer that's width bytes long to b.
+func putUint(order binary.ByteOrder, b *bytes.Buffer, width int64, v int64) {
+	field := make([]byte, width)
+	if width == 8 {
+		order.PutUint64(field, uint64(v))
+	} else {
+		order.PutUint32(field, uint32(v))
+	}
+
+	b.Write(field)
+}
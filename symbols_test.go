@@ -0,0 +1,70 @@
+package ar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testSymbols(t *testing.T, file string) {
+	in, err := os.Open(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	arReader := NewReader(in)
+
+	names := make([]string, 0)
+	for {
+		header, err := arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header == nil {
+			break
+		}
+
+		names = append(names, header.Name)
+	}
+
+	if len(names) != 2 || names[0] != "exit.o" || names[1] != "hello.o" {
+		t.Fatal("Symbol table entries shouldn't be returned by Next.")
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil {
+		t.Fatal("Reader should have parsed the symbol table.")
+	}
+
+	if len(symbols.Symbols) != 4 {
+		t.Error("Symbol table should contain 4 symbols.")
+	}
+
+	expected := map[string]string{"exit": "exit.o", "hello": "hello.o", "maybe": "hello.o", "world": "hello.o"}
+	for name, member := range expected {
+		sym := symbols.Lookup(name)
+		if sym == nil {
+			t.Error("Symbol table is missing " + name + ".")
+			continue
+		}
+
+		if sym.Member != member {
+			t.Error("Symbol " + name + " should be defined by " + member + ".")
+		}
+	}
+}
+
+func TestGNUSymbols(t *testing.T) {
+	testSymbols(t, "gnu_symbols.a")
+}
+
+func TestBSDSymbols(t *testing.T) {
+	testSymbols(t, "bsd_symbols.a")
+}
+
+func TestInvalidSymbolTable(t *testing.T) {
+	_, err := parseGNUSymbolTable([]byte{0, 0, 0, 2, 0, 0, 0, 8}, 4)
+	if err != ErrSymbolTable {
+		t.Error("Parsing a truncated table should return ErrSymbolTable.")
+	}
+}
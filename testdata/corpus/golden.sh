@@ -0,0 +1,37 @@
+#!/bin/sh
+# golden.sh writes the golden listing for each archive given, from the LLVM
+# tools' view of it rather than this package's. Members come from llvm-ar tv,
+# with times in UTC, and the symbol table from llvm-nm --print-armap. Both are
+# rewritten into the listing format corpus_test.go compares against:
+#
+#   <mode> <uid>/<gid> <size> <YYYY-MM-DDTHH:MM> <name>
+#   symbol <name> <member>
+#
+# Run it from testdata/corpus, e.g. ./golden.sh *.a *.lib. Set LLVM_AR and
+# LLVM_NM for versioned tool names like llvm-ar-14.
+set -e
+
+for archive in "$@"; do
+	{
+		TZ=UTC "${LLVM_AR:-llvm-ar}" tv "$archive" | awk '
+			BEGIN {
+				split("Jan Feb Mar Apr May Jun Jul Aug Sep Oct Nov Dec", months, " ")
+				for (i = 1; i <= 12; i++) {
+					month[months[i]] = i
+				}
+			}
+			{
+				name = $0
+				for (i = 1; i <= 7; i++) {
+					sub(/^[^ ]+ +/, "", name)
+				}
+				printf "%s %s %s %s-%02d-%02dT%s %s\n", $1, $2, $3, $7, month[$4], $5, $6, name
+			}'
+
+		# Thin archive members aren't present, only the map is needed.
+		"${LLVM_NM:-llvm-nm}" --print-armap "$archive" 2>/dev/null | awk '
+			/^Archive map$/ { inMap = 1; next }
+			inMap && NF == 0 { exit }
+			inMap { print "symbol", $1, $3 }' || true
+	} > "$archive.golden"
+done
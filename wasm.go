@@ -0,0 +1,166 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	errWasmObject = errors.New("ar: invalid WebAssembly object")
+)
+
+// wasmMagic identifies WebAssembly modules, followed by the version.
+var wasmMagic = []byte("\x00asm")
+
+// WebAssembly object linking metadata, from the tool conventions Linking.md.
+const (
+	wasmLinkingVersion = 2
+	wasmSymbolTable    = 8 // WASM_SYMBOL_TABLE subsection.
+
+	wasmFunction = 0 // SYMTAB_FUNCTION.
+	wasmData     = 1 // SYMTAB_DATA.
+	wasmGlobal   = 2 // SYMTAB_GLOBAL.
+	wasmSection  = 3 // SYMTAB_SECTION.
+	wasmTable    = 5 // SYMTAB_TABLE, the last kind.
+
+	wasmLocal        = 0x02 // WASM_SYM_BINDING_LOCAL.
+	wasmUndefined    = 0x10 // WASM_SYM_UNDEFINED.
+	wasmExplicitName = 0x40 // WASM_SYM_EXPLICIT_NAME.
+)
+
+// wasmSymbols returns the names of the function, data and global symbols
+// defined by the WebAssembly object contents, from the symbol table in the
+// linking custom section. Local and undefined symbols are left out.
+func wasmSymbols(name string, contents []byte) ([]string, error) {
+	if len(contents) < 8 || !bytes.HasPrefix(contents, wasmMagic) ||
+		binary.LittleEndian.Uint32(contents[4:]) != 1 {
+		return nil, errWasmObject
+	}
+
+	sections := &wasmReader{b: contents[8:]}
+	for len(sections.b) > 0 {
+		id := sections.byte()
+		section := &wasmReader{b: sections.bytes()}
+		if sections.err != nil {
+			return nil, sections.err
+		}
+
+		if id == 0 && string(section.bytes()) == "linking" {
+			return wasmLinkingSymbols(section)
+		}
+	}
+
+	// Modules that aren't relocatable objects don't define any symbols.
+	return []string{}, nil
+}
+
+// wasmLinkingSymbols returns the defined symbols in the symbol table
+// subsection of the linking section read by section.
+func wasmLinkingSymbols(section *wasmReader) ([]string, error) {
+	if section.uint() != wasmLinkingVersion {
+		return nil, errWasmObject
+	}
+
+	names := make([]string, 0)
+	for len(section.b) > 0 {
+		typ := section.byte()
+		sub := &wasmReader{b: section.bytes()}
+		if section.err != nil {
+			return nil, section.err
+		}
+		if typ != wasmSymbolTable {
+			continue
+		}
+
+		count := sub.uint()
+		for i := uint32(0); i < count && sub.err == nil; i++ {
+			kind := sub.byte()
+			flags := sub.uint()
+			if kind > wasmTable {
+				return nil, errWasmObject
+			}
+
+			var sym string
+			switch kind {
+			case wasmData:
+				sym = string(sub.bytes())
+				if flags&wasmUndefined == 0 {
+					// Segment index, offset and size.
+					sub.uint()
+					sub.uint()
+					sub.uint()
+				}
+			case wasmSection:
+				sub.uint()
+				continue
+			default:
+				// Functions, globals, tags and tables have an index, and names
+				// unless they're imported without one.
+				sub.uint()
+				if flags&wasmUndefined == 0 || flags&wasmExplicitName != 0 {
+					sym = string(sub.bytes())
+				}
+			}
+
+			if kind > wasmGlobal || flags&(wasmLocal|wasmUndefined) != 0 || sym == "" {
+				continue
+			}
+
+			names = append(names, sym)
+		}
+		if sub.err != nil {
+			return nil, sub.err
+		}
+	}
+
+	return names, nil
+}
+
+// wasmReader reads the LEB128 encoded values of a WebAssembly module from b,
+// the first error is kept in err and zero values are returned after it.
+type wasmReader struct {
+	b   []byte
+	err error
+}
+
+// byte reads a single byte.
+func (wr *wasmReader) byte() byte {
+	if wr.err != nil || len(wr.b) == 0 {
+		wr.err = errWasmObject
+		return 0
+	}
+
+	c := wr.b[0]
+	wr.b = wr.b[1:]
+	return c
+}
+
+// uint reads a varuint32.
+func (wr *wasmReader) uint() uint32 {
+	if wr.err != nil {
+		return 0
+	}
+
+	v, n := binary.Uvarint(wr.b)
+	if n <= 0 || n > 5 || v > 0xffffffff {
+		wr.err = errWasmObject
+		return 0
+	}
+
+	wr.b = wr.b[n:]
+	return uint32(v)
+}
+
+// bytes reads a varuint32 length followed by that many bytes.
+func (wr *wasmReader) bytes() []byte {
+	n := wr.uint()
+	if wr.err != nil || int64(n) > int64(len(wr.b)) {
+		wr.err = errWasmObject
+		return nil
+	}
+
+	b := wr.b[:n]
+	wr.b = wr.b[n:]
+	return b
+}
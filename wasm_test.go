@@ -0,0 +1,86 @@
+package ar
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWasmSymbols(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "wasm.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same symbols llvm-ar adds to the archive index.
+	names, err := wasmSymbols("wasm.o", contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"hello", "counter", "read_stack_top", "stack_top", "hidden_value", "weak_value"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Symbols are %v, expected %v.", names, expected)
+	}
+
+	var out bytes.Buffer
+	arWriter := NewWriter(&out)
+	err = arWriter.WriteHeader(&Header{Name: "wasm.o", Mode: 0644, Size: int64(len(contents))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = arWriter.Write(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader := NewReader(&out)
+	_, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != len(expected) || symbols.Lookup("stack_top").Member != "wasm.o" {
+		t.Error("Symbol table should contain the symbols defined by the object.")
+	}
+}
+
+func TestInvalidWasmObject(t *testing.T) {
+	contents, err := ioutil.ReadFile(filepath.Join("testdata", "wasm.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncations are invalid, end before the linking section, or include
+	// the whole linking section.
+	complete, err := wasmSymbols("wasm.o", contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < len(contents); i++ {
+		names, err := wasmSymbols("wasm.o", contents[:i])
+		if err == nil && len(names) != 0 && !reflect.DeepEqual(names, complete) {
+			t.Fatalf("Object truncated to %d bytes shouldn't define partial symbols.", i)
+		}
+	}
+
+	names, err := wasmSymbols("module.wasm", []byte("\x00asm\x01\x00\x00\x00"))
+	if err != nil || len(names) != 0 {
+		t.Error("Modules without a linking section shouldn't define symbols.")
+	}
+
+	_, err = wasmSymbols("wasm.o", []byte("\x00asm\x02\x00\x00\x00"))
+	if err != errWasmObject {
+		t.Error("Unsupported versions should be invalid.")
+	}
+}
@@ -2,10 +2,15 @@ package ar
 
 import (
 	"bytes"
+	"debug/macho"
 	"encoding/binary"
 	"errors"
 	"io"
+	"io/ioutil"
+	"math"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,26 +18,80 @@ var (
 	ErrWriteAfterClose = errors.New("ar: write after close")
 	ErrWriteTooLong    = errors.New("ar: write too long")
 	ErrHeaderTooLong   = errors.New("ar: header too long")
+	ErrInvalidName     = errors.New("ar: name can't be represented in the archive format")
 )
 
-// entry contains the entry name an the byte offset to its header in the
-// file entries buffer.
+// entry contains a symbol name and the byte offset to the header of the file
+// entry defining it in the file entries buffer.
 type entry struct {
-	Name   string
-	Offset int64
+	Name     string
+	Offset   int64
+	Object64 bool // If it's defined by a 64 bit XCOFF object.
 }
 
-// Writer provides sequential writing to an ar archive using the GNU format.
-// WriteHeader triggers a new entry to be written, aftwards the writer can be
-// used as an io.Writer.
+// WriterOptions configures a Writer created with NewWriterOptions.
+type WriterOptions struct {
+	// Format is the variant of the archive written, defaults to FormatGNU.
+	Format Format
+
+	// Streaming spools file entries to a temporary file instead of memory,
+	// so memory use stays bounded regardless of the archive size.
+	Streaming bool
+
+	// TempDir is the directory the streaming spool file is created in. If
+	// empty the default directory for temporary files is used.
+	TempDir string
+
+	// Deterministic zeroes timestamps, uids and gids, and uses 0644 for the
+	// mode of every entry, like GNU ar D. If SOURCE_DATE_EPOCH is set it's
+	// used for the timestamps instead.
+	Deterministic bool
+
+	// ValidateNames makes WriteHeader return ErrInvalidName for names that
+	// a Reader with the default options wouldn't read back unchanged in the
+	// format, instead of writing them as is. Names are always stored byte for
+	// byte.
+	ValidateNames bool
+
+	// MachOCPU is the architecture fat Mach-O members are indexed for, like
+	// Apple libtool -arch_only, using the symbols of their object for it. If
+	// it's zero, or a member has no object for it, the member is left out of
+	// the symbol table, and FormatBSD archives return a *SymbolError for it.
+	MachOCPU macho.Cpu
+}
+
+// buffer holds the standard file entries until they're copied on Close, the
+// contents of an entry are read back from it to create the symbol table.
+type buffer interface {
+	io.Writer
+	io.WriterTo
+	io.ReaderAt
+}
+
+// Writer provides sequential writing to an ar archive using the GNU format,
+// or the format set in its WriterOptions. WriteHeader triggers a new entry to
+// be written, aftwards the writer can be used as an io.Writer.
 type Writer struct {
-	writer  io.Writer
-	symbols []*entry      // Contains the list for the GNU symbol table.
-	strings *bytes.Buffer // Contains the GNU strings table.
-	buf     *bytes.Buffer // Contains standard file entries.
-	uw      int64         // Unwritten bytes for the current entry.
-	pad     bool          // If the entry should contain the padding byte.
-	closed  bool
+	writer        io.Writer
+	format        Format
+	deterministic bool          // If headers are normalized for reproducible output.
+	validate      bool          // If names are validated for the format.
+	cpu           macho.Cpu     // Architecture fat Mach-O members are indexed for.
+	symbols       []*entry      // Contains the list for the GNU symbol table.
+	members       []int64       // Offsets in buf to each standard entry header.
+	strings       *bytes.Buffer // Contains the GNU strings table, or AIX member names.
+	buf           buffer        // Contains standard file entries.
+	buflen        int64         // Bytes written to buf.
+	thin          buffer        // Contains the contents of FormatGNUThin entries.
+	thinlen       int64         // Bytes written to thin.
+	magic         []byte        // Start of the current entry if it may be an object.
+	name          string        // Name of the current entry.
+	offset        int64         // Offset in buf to the current entry header.
+	start         int64         // Offset in buf, or thin, to the current entry contents.
+	written       int64         // Bytes written for the current entry.
+	uw            int64         // Unwritten bytes for the current entry.
+	pad           bool          // If the entry should contain the padding byte.
+	closed        bool
 }
 
 // NewWriter creates a Writer writing to w.
@@ -40,35 +99,103 @@ func NewWriter(w io.Writer) *Writer {
 	return &Writer{
 		writer:  w,
 		symbols: make([]*entry, 0),
+		members: make([]int64, 0),
 		strings: new(bytes.Buffer),
-		buf:     new(bytes.Buffer),
+		buf:     new(memBuffer),
 	}
 }
 
+// NewWriterOptions creates a Writer writing to w configured by opts. An error
+// is returned if the streaming spool file can't be created.
+func NewWriterOptions(w io.Writer, opts WriterOptions) (*Writer, error) {
+	arw := NewWriter(w)
+	arw.format = opts.Format
+	arw.deterministic = opts.Deterministic
+	arw.validate = opts.ValidateNames
+	arw.cpu = opts.MachOCPU
+
+	// Thin archives don't store the contents, so they're kept separately.
+	if opts.Streaming && arw.format == FormatGNUThin {
+		spool, err := newSpool(opts.TempDir)
+		if err != nil {
+			return nil, err
+		}
+
+		arw.thin = spool
+	} else if opts.Streaming {
+		spool, err := newSpool(opts.TempDir)
+		if err != nil {
+			return nil, err
+		}
+
+		arw.buf = spool
+	} else if arw.format == FormatGNUThin {
+		arw.thin = new(memBuffer)
+	}
+
+	return arw, nil
+}
+
 // WriteHeader creates a new file entry for header. Calling after it's closed
 // will return ErrWriteAfterClose. ErrHeaderTooLong is returned if the header
-// won't fit.
+// won't fit, and a *SymbolError if the previous entry is an object whose
+// symbols can't be indexed, see SymbolExtractor.
 func (arw *Writer) WriteHeader(header *Header) error {
 	if arw.closed {
 		return ErrWriteAfterClose
 	}
+	if arw.validate && !validName(arw.format, header.Name) {
+		return ErrInvalidName
+	}
+
+	err := arw.fillUnwritten()
+	if err != nil {
+		return err
+	}
 
-	err := arw.fillUnwritten(arw.buf)
+	err = arw.indexObject()
 	if err != nil {
 		return err
 	}
 
-	hdr, err := arw.createHeader(true, header)
+	// In memory the contents of the previous thin entry aren't needed.
+	if mb, ok := arw.thin.(*memBuffer); ok {
+		mb.Reset()
+		arw.thinlen = 0
+	}
+
+	if arw.deterministic {
+		normalized := *header
+		normalized.ModTime = arw.tableTime()
+		normalized.Uid = 0
+		normalized.Gid = 0
+		normalized.Mode = 0644
+		header = &normalized
+	}
+
+	var hdr []byte
+	if arw.format == FormatAIXBig {
+		hdr, err = arw.createBigHeader(header)
+	} else {
+		hdr, err = arw.createHeader(true, header)
+	}
 	if err != nil {
 		return err
 	}
 
-	_, err = arw.buf.Write(hdr)
+	_, err = arw.writeBuf(hdr)
+	arw.start = arw.buflen
+	if arw.format == FormatGNUThin {
+		arw.start = arw.thinlen
+	}
+	arw.written = 0
+
 	return err
 }
 
 // Write writes b to the current file entry. It returns ErrWriteTooLong if more
-// bytes are being written than the header allows.
+// bytes are being written than the header allows. For FormatGNUThin archives
+// the contents are only used to create the symbol table, and aren't stored.
 func (arw *Writer) Write(b []byte) (int, error) {
 	if arw.closed {
 		return 0, ErrWriteAfterClose
@@ -81,8 +208,18 @@ func (arw *Writer) Write(b []byte) (int, error) {
 		overwrite = true
 	}
 
-	n, err := arw.buf.Write(b)
+	// Thin archives only use the contents for the symbol table, so they're
+	// only kept while the entry may be an object.
+	arw.captureMagic(b)
+	n, err := len(b), error(nil)
+	if arw.format != FormatGNUThin {
+		n, err = arw.writeBuf(b)
+	} else if arw.magic != nil {
+		n, err = arw.thin.Write(b)
+		arw.thinlen += int64(n)
+	}
 	arw.uw -= int64(n)
+	arw.written += int64(n)
 	if err == nil && overwrite {
 		err = ErrWriteTooLong
 	}
@@ -91,24 +228,65 @@ func (arw *Writer) Write(b []byte) (int, error) {
 }
 
 // Close closes the ar archive creating the symbol/string tables. All writing
-// to the underlying writer is delayed until Close.
+// to the underlying writer is delayed until Close. A *SymbolError is returned
+// if the last entry is an object whose symbols can't be indexed, see
+// SymbolExtractor.
 func (arw *Writer) Close() error {
 	if arw.closed {
 		return nil
 	}
 	arw.closed = true
 
-	err := arw.fillUnwritten(arw.buf)
+	// Remove the spool file regardless of how writing ends.
+	if closer, ok := arw.buf.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if closer, ok := arw.thin.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	err := arw.fillUnwritten()
+	if err != nil {
+		return err
+	}
+
+	err = arw.indexObject()
 	if err != nil {
 		return err
 	}
 
+	switch arw.format {
+	case FormatAIXBig:
+		// The tables follow the file entries.
+		return arw.writeAIXBig()
+	case FormatBSD:
+		err = arw.writeBSDTables()
+	case FormatCOFF:
+		err = arw.writeCOFFTables()
+	case FormatCommon:
+		_, err = arw.writer.Write([]byte("!<arch>\n"))
+	default:
+		err = arw.writeGNUTables()
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = arw.buf.WriteTo(arw.writer)
+	return err
+}
+
+// writeGNUTables writes the magic number, and the GNU symbol and strings
+// tables. It's used for both regular and thin archives.
+func (arw *Writer) writeGNUTables() error {
+	var err error
+
 	// Create strings header, only populated if there's any data in the entry.
 	strHeader := make([]byte, 0)
 	if arw.strings.Len() > 0 {
 		strHeader, err = arw.createHeader(false, &Header{
 			Name:    "//",
-			ModTime: time.Now(),
+			ModTime: arw.tableTime(),
 			Uid:     0,
 			Gid:     0,
 			Mode:    0,
@@ -120,34 +298,19 @@ func (arw *Writer) Close() error {
 	}
 
 	// Calculate the size of the data before file entries, used to complete
-	// the symbol offsets.
-	size := int64(68)                         // Magic num + header size.
-	size += int64(4 + (4 * len(arw.symbols))) // Size of symbol table.
-	for _, entry := range arw.symbols {
-		size += int64(len(entry.Name + "\u0000"))
-	}
-	if (size-68)%2 != 0 {
-		size++
-	}
-	size += int64(len(strHeader) + arw.strings.Len()) // Strings header + table.
-	if arw.strings.Len()%2 != 0 {
-		size++
+	// the symbol offsets. Switch to the 64 bit table if an offset won't fit.
+	width := int64(4)
+	size := arw.gnuTablesSize(width, len(strHeader))
+	if exceedsUint32(arw.symbols, size) {
+		width = 8
+		size = arw.gnuTablesSize(width, len(strHeader))
 	}
 
 	// Create the symbol table.
 	var symTable bytes.Buffer
-	err = binary.Write(&symTable, binary.BigEndian, int32(len(arw.symbols)))
-	if err != nil {
-		return err
-	}
+	putUint(binary.BigEndian, &symTable, width, int64(len(arw.symbols)))
 	for _, entry := range arw.symbols {
-		// Also set the final offset.
-		entry.Offset += size
-
-		err = binary.Write(&symTable, binary.BigEndian, int32(entry.Offset))
-		if err != nil {
-			return err
-		}
+		putUint(binary.BigEndian, &symTable, width, entry.Offset+size)
 	}
 	for _, entry := range arw.symbols {
 		_, err = symTable.Write([]byte(entry.Name + "\u0000"))
@@ -157,9 +320,13 @@ func (arw *Writer) Close() error {
 	}
 
 	// Create symbol header.
+	symName := "/"
+	if width == 8 {
+		symName = "/SYM64/"
+	}
 	symHeader, err := arw.createHeader(false, &Header{
-		Name:    "/",
-		ModTime: time.Now(),
+		Name:    symName,
+		ModTime: arw.tableTime(),
 		Uid:     0,
 		Gid:     0,
 		Mode:    0,
@@ -169,7 +336,11 @@ func (arw *Writer) Close() error {
 		return err
 	}
 
-	_, err = arw.writer.Write([]byte("!<arch>\n"))
+	magic := "!<arch>\n"
+	if arw.format == FormatGNUThin {
+		magic = "!<thin>\n"
+	}
+	_, err = arw.writer.Write([]byte(magic))
 	if err != nil {
 		return err
 	}
@@ -204,26 +375,82 @@ func (arw *Writer) Close() error {
 			}
 		}
 		_, err = io.Copy(arw.writer, arw.strings)
-		if err != nil {
-			return err
-		}
 	}
 
-	_, err = io.Copy(arw.writer, arw.buf)
 	return err
 }
 
+// tableTime returns the modification time for the generated table entries,
+// and for every entry in deterministic mode. SOURCE_DATE_EPOCH is used when
+// it's set to a valid timestamp.
+func (arw *Writer) tableTime() time.Time {
+	epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+	if err == nil {
+		return time.Unix(epoch, 0)
+	}
+
+	if arw.deterministic {
+		return time.Unix(0, 0)
+	}
+
+	return time.Now()
+}
+
+// gnuTablesSize returns the size of the magic number, symbol table using
+// width byte offsets, and a strings table with a header strHeader long.
+func (arw *Writer) gnuTablesSize(width int64, strHeader int) int64 {
+	size := width + (width * int64(len(arw.symbols)))
+	for _, entry := range arw.symbols {
+		size += int64(len(entry.Name + "\u0000"))
+	}
+	if size%2 != 0 {
+		size++
+	}
+	size += 68 // Magic num + header size.
+
+	size += int64(strHeader + arw.strings.Len()) // Strings header + table.
+	if arw.strings.Len()%2 != 0 {
+		size++
+	}
+
+	return size
+}
+
+// exceedsUint32 checks if any symbol offset is too large for 32 bits once the
+// size of the data before file entries is added.
+func exceedsUint32(symbols []*entry, size int64) bool {
+	for _, entry := range symbols {
+		if entry.Offset+size > math.MaxUint32 {
+			return true
+		}
+	}
+
+	return false
+}
+
 // createHeader creates the header entry, if standard / is added to names, and
-// strings/symbol tables are written.
+// strings/symbol tables are written. For the BSD format the padded name is
+// included after the header.
 func (arw *Writer) createHeader(standard bool, header *Header) ([]byte, error) {
 	// Get name and detect if extended.
 	offset := ""
-	name := toASCII(header.Name)
-	if standard {
+	name := header.Name
+	var extName []byte
+	if arw.format == FormatBSD {
+		// The symbol table is the only non standard entry, following the magic.
+		at := int64(8)
+		if standard {
+			at = arw.buflen
+		}
+
+		extName = bsdName(name, at)
+		name = "#1/" + strconv.Itoa(len(extName))
+	} else if standard && arw.format != FormatCommon {
 		name += "/"
 	}
-	if len(name) > 16 {
-		if !standard {
+	// Thin archives store every name in the strings table.
+	if len(name) > 16 || (standard && arw.format == FormatGNUThin) {
+		if !standard || arw.format == FormatCommon {
 			return nil, ErrHeaderTooLong
 		}
 
@@ -247,44 +474,54 @@ func (arw *Writer) createHeader(standard bool, header *Header) ([]byte, error) {
 		return nil, ErrHeaderTooLong
 	}
 
-	// Format the mode, and ensure it fits.
-	mode := strconv.FormatInt(header.Mode, 8)
-	if standard {
-		mode = "100" + mode
+	// Format the mode adding the regular file type if it's only permissions,
+	// and ensure it fits.
+	modeInt := header.Mode
+	if standard && modeInt&^07777 == 0 {
+		modeInt |= 0100000
 	}
+	mode := strconv.FormatInt(modeInt, 8)
 	if len(mode) > 8 {
 		return nil, ErrHeaderTooLong
 	}
 
 	// Get size, and ensure it fits.
-	size := strconv.FormatInt(header.Size, 10)
+	size := strconv.FormatInt(header.Size+int64(len(extName)), 10)
 	if len(size) > 10 {
 		return nil, ErrHeaderTooLong
 	}
 
 	// Set unwritten and padding.
 	arw.uw = header.Size
-	if header.Size%2 == 0 {
+	if (header.Size+int64(len(extName)))%2 == 0 {
 		arw.pad = false
 	} else {
 		arw.pad = true
 	}
 
-	// Write to strings buffer if extended.
+	// Write to strings buffer if extended, COFF names are null terminated.
 	if offset != "" {
-		_, err := arw.strings.Write([]byte(offset + "\n"))
+		term := "\n"
+		if arw.format == FormatCOFF {
+			offset = offset[:len(offset)-1]
+			term = "\u0000"
+		}
+
+		_, err := arw.strings.Write([]byte(offset + term))
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	// Add item to symbol table.
+	// Start capturing the contents for the symbol table, the common format
+	// doesn't have one.
 	if standard {
-		entry := &entry{Name: name, Offset: int64(arw.buf.Len())}
-		if offset != "" {
-			entry.Name = offset
+		arw.offset = arw.buflen
+		arw.members = append(arw.members, arw.offset)
+		arw.name = header.Name
+		if arw.format != FormatCommon {
+			arw.magic = make([]byte, 0)
 		}
-		arw.symbols = append(arw.symbols, entry)
 	}
 
 	// Add content to fields.
@@ -297,11 +534,17 @@ func (arw *Writer) createHeader(standard bool, header *Header) ([]byte, error) {
 	arw.fillField(hdr[48:58], size)
 	arw.fillField(hdr[58:], "`\n")
 
-	return hdr, nil
+	return append(hdr, extName...), nil
 }
 
-// fillUnwritten writes any unwritten bytes and writes the padding byte to w.
-func (arw *Writer) fillUnwritten(w io.Writer) error {
+// fillUnwritten writes any unwritten bytes and writes the padding byte.
+func (arw *Writer) fillUnwritten() error {
+	if arw.format == FormatGNUThin {
+		arw.uw = 0
+		arw.pad = false
+		return nil
+	}
+
 	fill := make([]byte, arw.uw)
 	for i := range fill {
 		fill[i] = ' '
@@ -310,11 +553,108 @@ func (arw *Writer) fillUnwritten(w io.Writer) error {
 	if arw.pad {
 		fill = append(fill, '\n')
 	}
+	arw.uw = 0
+	arw.pad = false
 
-	_, err := w.Write(fill)
+	_, err := arw.writeBuf(fill)
 	return err
 }
 
+// captureMagic adds the start of b to the captured start of the current
+// entry, the capture is dropped once it can't match a SymbolExtractor.
+func (arw *Writer) captureMagic(b []byte) {
+	if arw.magic == nil {
+		return
+	}
+
+	n := longestMagic() - len(arw.magic)
+	if n > len(b) {
+		n = len(b)
+	}
+	if n > 0 {
+		arw.magic = append(arw.magic, b[:n]...)
+	}
+
+	if !maybeExtractable(arw.magic) {
+		arw.magic = nil
+	}
+}
+
+// indexObject adds the symbols defined by the current entry to the symbol
+// table, using the SymbolExtractor matching its contents which are read back
+// from the buffer. Entries without one or that can't be parsed are left out
+// of the table, a *SymbolError is returned for unsupported objects unless
+// they're only linked from archives in another format.
+func (arw *Writer) indexObject() error {
+	magic := arw.magic
+	arw.magic = nil
+	if magic == nil {
+		return nil
+	}
+
+	extractor := symbolExtractor(magic)
+	if extractor == nil {
+		return nil
+	}
+
+	contents := io.ReaderAt(arw.buf)
+	if arw.format == FormatGNUThin {
+		contents = arw.thin
+	}
+
+	// Fat Mach-O members are indexed for the selected architecture.
+	var names []string
+	var err error
+	section := io.NewSectionReader(contents, arw.start, arw.written)
+	if arw.cpu != 0 && bytes.HasPrefix(magic, machoFatMagic) {
+		names, err = machoFatSymbols(section, arw.cpu)
+	} else {
+		names, err = extractor.ExtractSymbols(arw.name, section, arw.written)
+	}
+	if errors.Is(err, ErrUnsupportedObject) {
+		format, ok := objectFormat(magic)
+		if !ok || format == arw.format {
+			return &SymbolError{Name: arw.name, Err: err}
+		}
+	}
+	if err != nil {
+		return nil
+	}
+
+	object64 := bytes.HasPrefix(magic, xcoff64Magic)
+	for _, name := range names {
+		arw.symbols = append(arw.symbols, &entry{Name: name, Offset: arw.offset, Object64: object64})
+	}
+
+	return nil
+}
+
+// objectFormat returns the archive format objects starting with magic are
+// linked from, if only one is. COFF objects are read from COFF archives by
+// link.exe, and Mach-O objects from BSD archives by ld64.
+func objectFormat(magic []byte) (Format, bool) {
+	for _, coff := range coffMagics {
+		if bytes.HasPrefix(magic, coff) {
+			return FormatCOFF, true
+		}
+	}
+	for _, macho := range machoMagics {
+		if bytes.HasPrefix(magic, macho) {
+			return FormatBSD, true
+		}
+	}
+
+	return 0, false
+}
+
+// writeBuf writes b to the file entries buffer, keeping track of its length.
+func (arw *Writer) writeBuf(b []byte) (int, error) {
+	n, err := arw.buf.Write(b)
+	arw.buflen += int64(n)
+
+	return n, err
+}
+
 // fillField writes a string and any padding to a byte slice.
 func (arw *Writer) fillField(field []byte, contents string) {
 	clen := len(contents)
@@ -329,15 +669,92 @@ func (arw *Writer) fillField(field []byte, contents string) {
 	}
 }
 
-// toASCII strips non ascii characters from s.
-func toASCII(s string) string {
-	n := make([]rune, 0)
-
-	for _, c := range s {
-		if c < 0x80 {
-			n = append(n, c)
+// validName checks if name can be written in format and read back unchanged.
+// Names the reader treats as tables or extended names are rejected.
+func validName(format Format, name string) bool {
+	if name == "" || strings.ContainsRune(name, 0) {
+		return false
+	}
+	if format == FormatAIXBig {
+		return true
+	}
+
+	// Trailing slashes are stripped, __.SYMDEF names are symbol tables, and
+	// Go metadata is skipped unless ReaderOptions.GoMetadata is set.
+	if strings.HasSuffix(name, "/") || strings.HasPrefix(name, "__.SYMDEF") ||
+		name == "__.PKGDEF" || name == "__.GOSYMDEF" {
+		return false
+	}
+
+	switch format {
+	case FormatBSD:
+		// The name follows the header and is padded with nulls.
+		return name != "//" && name != "/SYM64/" && !strings.HasSuffix(name, " ")
+	case FormatGNUThin:
+		// Every name is stored in the strings table.
+		return !strings.ContainsRune(name, '\n')
+	case FormatCommon:
+		if strings.HasSuffix(name, " ") {
+			return false
 		}
 	}
 
-	return string(n)
+	// Names in the header can't look like table or extended names, and
+	// names in the strings table are terminated by newlines.
+	return !strings.HasPrefix(name, "/") && !strings.HasPrefix(name, "#1/") &&
+		!strings.ContainsRune(name, '\n')
+}
+
+// memBuffer is a buffer held in memory.
+type memBuffer struct {
+	bytes.Buffer
+}
+
+// ReadAt reads from the unread portion of the buffer at off.
+func (mb *memBuffer) ReadAt(b []byte, off int64) (int, error) {
+	return bytes.NewReader(mb.Bytes()).ReadAt(b, off)
+}
+
+// spool is a buffer backed by a temporary file, it's removed when closed.
+type spool struct {
+	file *os.File
+}
+
+// newSpool creates a spool with a temporary file in dir.
+func newSpool(dir string) (*spool, error) {
+	file, err := ioutil.TempFile(dir, "ar")
+	if err != nil {
+		return nil, err
+	}
+
+	return &spool{file: file}, nil
+}
+
+func (sp *spool) Write(b []byte) (int, error) {
+	return sp.file.Write(b)
+}
+
+func (sp *spool) ReadAt(b []byte, off int64) (int, error) {
+	return sp.file.ReadAt(b, off)
+}
+
+// WriteTo copies the spooled contents from the start of the file to w.
+func (sp *spool) WriteTo(w io.Writer) (int64, error) {
+	_, err := sp.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+
+	return io.Copy(w, sp.file)
+}
+
+// Close closes and removes the temporary file.
+func (sp *spool) Close() error {
+	err := sp.file.Close()
+	rerr := os.Remove(sp.file.Name())
+	if err == nil {
+		err = rerr
+	}
+
+	return err
 }
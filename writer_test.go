@@ -2,10 +2,13 @@ package ar
 
 import (
 	"bytes"
+	"encoding/binary"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 var (
@@ -266,3 +269,523 @@ func TestHeaderTooLong(t *testing.T) {
 		t.Error("WriteHeader should've returned ErrHeaderTooLong but didn't.")
 	}
 }
+
+func TestStreamingWrite(t *testing.T) {
+	out, err := os.Create(filepath.Join("testdata", "out", "writer_test_streaming.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	spoolDir := filepath.Join("testdata", "out", "spool")
+	err = os.MkdirAll(spoolDir, os.ModePerm|os.ModeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arWriter, err := NewWriterOptions(out, WriterOptions{Streaming: true, TempDir: spoolDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in, err := os.Open(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := FileInfoHeader(stat)
+
+	err = arWriter.WriteHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = io.Copy(arWriter, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spooled, err := ioutil.ReadDir(spoolDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spooled) != 0 {
+		t.Error("Close should remove the spool file.")
+	}
+}
+
+func TestStreamingVerify(t *testing.T) {
+	streamed, err := ioutil.ReadFile(filepath.Join("testdata", "out", "writer_test_streaming.a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arReader := NewReader(bytes.NewReader(streamed))
+
+	header, err := arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil {
+		t.Fatal("Reader should find at least one entry.")
+	}
+
+	if header.Name != "exit.o" {
+		t.Error("Header name isn't what it should be.")
+	}
+
+	contents, err := ioutil.ReadAll(arReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	object, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(contents, object) {
+		t.Error("Entry contents don't match the written file.")
+	}
+}
+
+func TestStreamingSymbols(t *testing.T) {
+	object, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []Format{FormatGNU, FormatGNUThin} {
+		var out bytes.Buffer
+		arWriter, err := NewWriterOptions(&out, WriterOptions{Format: format, Streaming: true, TempDir: t.TempDir()})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Small writes split the magic number across calls.
+		files := map[string][]byte{"README": []byte("not an object\n"), "exit.o": object}
+		for _, name := range []string{"README", "exit.o"} {
+			err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for b := files[name]; len(b) > 0; b = b[1:] {
+				_, err = arWriter.Write(b[:1])
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+
+		err = arWriter.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		arReader, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		symbols := arReader.Symbols()
+		if symbols == nil || len(symbols.Symbols) != 1 || symbols.Lookup("exit").Member != "exit.o" {
+			t.Errorf("Format %v symbol table should only contain the exit symbol from exit.o.", format)
+		}
+	}
+}
+
+func TestSymbolTable(t *testing.T) {
+	var out bytes.Buffer
+	arWriter := NewWriter(&out)
+
+	object, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{"README": []byte("not an object\n"), "exit.o": object}
+	for _, name := range []string{"README", "exit.o"} {
+		err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := out.Bytes()
+	table := archive[68:]
+	if string(archive[8:10]) != "/ " {
+		t.Fatal("Archive should start with the symbol table.")
+	}
+
+	if binary.BigEndian.Uint32(table) != 1 {
+		t.Fatal("Symbol table should only contain the symbol from exit.o.")
+	}
+
+	if string(table[8:13]) != "exit\x00" {
+		t.Error("Symbol table should contain the exit symbol.")
+	}
+
+	offset := binary.BigEndian.Uint32(table[4:])
+	if string(archive[offset:offset+7]) != "exit.o/" {
+		t.Error("Symbol offset should point at the exit.o header.")
+	}
+}
+
+func TestBSDWrite(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatBSD})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	names := []string{"notes.txt", "hello.o", "exit.o"}
+	files["notes.txt"] = []byte("odd sized\n")
+	for _, name := range names[1:] {
+		files[name], err = ioutil.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range names {
+		err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: int64(len(files[name]))})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = arWriter.Write(files[name])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	arReader, err := OpenReaderAt(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := arReader.Members()
+	if len(members) != len(names) {
+		t.Fatal("Reader should find every written entry.")
+	}
+
+	for i, header := range members {
+		if header.Name != names[i] {
+			t.Error("Header name isn't what it should be.")
+		}
+
+		if arReader.offsets[header]%8 != 0 {
+			t.Error("Entry contents should be 8 byte aligned.")
+		}
+
+		contents, err := ioutil.ReadAll(arReader.Section(header))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(contents, files[header.Name]) {
+			t.Error("Entry contents don't match the written file.")
+		}
+	}
+
+	symbols := arReader.Symbols()
+	if symbols == nil || len(symbols.Symbols) != 4 {
+		t.Fatal("Symbol table should contain the symbols from both objects.")
+	}
+
+	expected := []string{"exit", "hello", "maybe", "world"}
+	for i, sym := range symbols.Symbols {
+		if sym.Name != expected[i] {
+			t.Error("Symbol table should be sorted by name.")
+		}
+	}
+
+	if symbols.Lookup("exit").Member != "exit.o" {
+		t.Error("Symbol exit should be defined by exit.o.")
+	}
+}
+
+func TestSymbolTable64(t *testing.T) {
+	for _, format := range []Format{FormatGNU, FormatBSD} {
+		var out bytes.Buffer
+		arWriter, err := NewWriterOptions(&out, WriterOptions{Format: format})
+		if err != nil {
+			t.Fatal(err)
+		}
+		arWriter.symbols = append(arWriter.symbols, &entry{Name: "far", Offset: 1 << 32})
+
+		if format == FormatBSD {
+			err = arWriter.writeBSDTables()
+		} else {
+			err = arWriter.writeGNUTables()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		arReader := NewReader(&out)
+		_, err = arReader.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		symbols := arReader.Symbols()
+		if symbols == nil || len(symbols.Symbols) != 1 {
+			t.Fatal("Reader should parse the 64 bit symbol table.")
+		}
+
+		if symbols.Symbols[0].Offset <= 1<<32 {
+			t.Error("Symbol offset should be past 4GiB.")
+		}
+	}
+}
+
+func TestThinWrite(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatGNUThin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	object, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.WriteHeader(&Header{Name: "exit.o", Mode: 0644, Size: int64(len(object))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = arWriter.Write(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(out.Bytes(), []byte("!<thin>\n")) {
+		t.Fatal("Archive should use the thin magic number.")
+	}
+
+	if out.Len() > len(object) {
+		t.Error("Thin archive shouldn't store entry contents.")
+	}
+
+	arReader := NewReader(&out)
+	header, err := arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header == nil || header.Name != "exit.o" || header.Size != int64(len(object)) {
+		t.Fatal("Reader should find the thin entry.")
+	}
+
+	if arReader.Symbols().Lookup("exit").Member != "exit.o" {
+		t.Error("Symbol table should contain the exit symbol.")
+	}
+}
+
+func writeDeterministic(t *testing.T, header *Header, contents []byte) []byte {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Deterministic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.WriteHeader(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = arWriter.Write(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Bytes()
+}
+
+func TestDeterministicWrite(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+
+	object, err := ioutil.ReadFile(filepath.Join("testdata", "exit.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := writeDeterministic(t, &Header{
+		Name: "exit.o", ModTime: time.Now(), Uid: 1000, Gid: 1000, Mode: 0755, Size: int64(len(object)),
+	}, object)
+	second := writeDeterministic(t, &Header{
+		Name: "exit.o", ModTime: time.Unix(1234, 0), Uid: 1, Gid: 2, Mode: 0600, Size: int64(len(object)),
+	}, object)
+	if !bytes.Equal(first, second) {
+		t.Fatal("Deterministic archives should be identical.")
+	}
+
+	arReader := NewReader(bytes.NewReader(first))
+	header, err := arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.ModTime.Unix() != 0 || header.Uid != 0 || header.Gid != 0 || header.Mode != 0100644 {
+		t.Error("Deterministic headers should be normalized.")
+	}
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	arReader = NewReader(bytes.NewReader(writeDeterministic(t, &Header{Name: "exit.o", Size: int64(len(object))}, object)))
+	header, err = arReader.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header.ModTime.Unix() != 1700000000 {
+		t.Error("Deterministic headers should use SOURCE_DATE_EPOCH.")
+	}
+}
+
+func TestCommonWrite(t *testing.T) {
+	var out bytes.Buffer
+	arWriter, err := NewWriterOptions(&out, WriterOptions{Format: FormatCommon})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.WriteHeader(&Header{Name: "control.tar.gz", Mode: 0644, Size: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = arWriter.WriteHeader(&Header{Name: "extremelysuperlongname.tar", Mode: 0644})
+	if err != ErrHeaderTooLong {
+		t.Error("WriteHeader should've returned ErrHeaderTooLong for long names.")
+	}
+
+	err = arWriter.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.HasPrefix(out.Bytes(), []byte("!<arch>\ncontrol.tar.gz  ")) || bytes.Contains(out.Bytes(), []byte("/")) {
+		t.Error("Common format shouldn't use tables or / terminated names.")
+	}
+}
+
+func TestUTF8Names(t *testing.T) {
+	names := []string{"café.o", "объектный_файл_с_длинным_именем.o"}
+
+	for _, format := range []Format{FormatGNU, FormatBSD, FormatCOFF, FormatAIXBig} {
+		var out bytes.Buffer
+		arWriter, err := NewWriterOptions(&out, WriterOptions{Format: format, ValidateNames: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range names {
+			err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644, Size: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = arWriter.Write([]byte("a"))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		err = arWriter.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		arReader := NewReader(&out)
+		for _, name := range names {
+			header, err := arReader.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header == nil || header.Name != name {
+				t.Errorf("Format %d should preserve the name %q, read %+v.", format, name, header)
+			}
+		}
+	}
+}
+
+func TestValidateNames(t *testing.T) {
+	invalid := map[Format][]string{
+		FormatGNU:     {"", "a\x00b", "dir/", "/12", "#1/5", "a\nb", "__.SYMDEF"},
+		FormatBSD:     {"", "trailing ", "//", "/SYM64/", "__.SYMDEF SORTED", "__.PKGDEF"},
+		FormatGNUThin: {"", "a\nb", "dir/"},
+		FormatCommon:  {"", "trailing ", "/", "#1/5", "__.PKGDEF", "__.GOSYMDEF"},
+		FormatAIXBig:  {"", "a\x00b"},
+	}
+	valid := map[Format][]string{
+		FormatGNU:     {"dir/a.o", "trailing ", "café.o"},
+		FormatBSD:     {"#1/5", "a\nb", "dir/a.o"},
+		FormatGNUThin: {"/abs/path/a.o"},
+		FormatCommon:  {"debian-binary"},
+		FormatAIXBig:  {"/", "trailing ", "__.PKGDEF"},
+	}
+
+	for format, names := range invalid {
+		arWriter, err := NewWriterOptions(ioutil.Discard, WriterOptions{Format: format, ValidateNames: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range names {
+			err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644})
+			if err != ErrInvalidName {
+				t.Errorf("Format %d should reject the name %q.", format, name)
+			}
+		}
+	}
+
+	for format, names := range valid {
+		arWriter, err := NewWriterOptions(ioutil.Discard, WriterOptions{Format: format, ValidateNames: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range names {
+			err = arWriter.WriteHeader(&Header{Name: name, Mode: 0644})
+			if err != nil {
+				t.Errorf("Format %d should accept the name %q: %v.", format, name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package ar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+var (
+	errXCOFFObject = errors.New("ar: invalid XCOFF object")
+)
+
+// xcoffMagic and xcoff64Magic identify 32 and 64 bit XCOFF objects, the
+// objects AIX big archives index in separate global symbol tables.
+var (
+	xcoffMagic   = []byte{0x01, 0xdf}
+	xcoff64Magic = []byte{0x01, 0xf7}
+)
+
+// Layout of XCOFF objects, from the AIX XCOFF documentation. The symbols are
+// the same size in both, 64 bit objects store every name in the strings
+// table.
+const (
+	xcoffHeaderSize   = 20
+	xcoff64HeaderSize = 24
+	xcoffSymbolSize   = 18
+
+	xcoffClassExternal     = 2   // C_EXT.
+	xcoffClassWeakExternal = 111 // C_WEAKEXT.
+)
+
+// xcoffSymbols returns the names of the external and weak symbols defined by
+// the XCOFF object read from r, the same symbols AIX ar adds to the global
+// symbol table.
+func xcoffSymbols(r io.ReaderAt) ([]string, error) {
+	header := make([]byte, xcoff64HeaderSize)
+	n, err := r.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	// Offset to the symbol table, and the number of symbols.
+	var offset, count int64
+	wide := bytes.HasPrefix(header, xcoff64Magic)
+	switch {
+	case wide && n == xcoff64HeaderSize:
+		offset = int64(binary.BigEndian.Uint64(header[8:]))
+		count = int64(binary.BigEndian.Uint32(header[20:]))
+	case bytes.HasPrefix(header, xcoffMagic) && n >= xcoffHeaderSize:
+		offset = int64(binary.BigEndian.Uint32(header[8:]))
+		count = int64(binary.BigEndian.Uint32(header[12:]))
+	default:
+		return nil, errXCOFFObject
+	}
+	if offset < 0 {
+		return nil, errXCOFFObject
+	}
+
+	// The strings table follows the symbols, starting with its size. It's
+	// left out if no names are stored in it.
+	symbols, err := ioutil.ReadAll(io.NewSectionReader(r, offset, count*xcoffSymbolSize+4))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(symbols)) < count*xcoffSymbolSize {
+		return nil, errXCOFFObject
+	}
+
+	var table []byte
+	if int64(len(symbols)) == count*xcoffSymbolSize+4 {
+		size := int64(binary.BigEndian.Uint32(symbols[count*xcoffSymbolSize:]))
+		table, err = ioutil.ReadAll(io.NewSectionReader(r, offset+count*xcoffSymbolSize, size))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0)
+	for i := int64(0); i < count; i++ {
+		sym := symbols[i*xcoffSymbolSize:]
+		section := int16(binary.BigEndian.Uint16(sym[12:]))
+		class := sym[16]
+		i += int64(sym[17]) // Skip the auxiliary entries.
+
+		if class != xcoffClassExternal && class != xcoffClassWeakExternal || section == 0 {
+			continue
+		}
+
+		// Long names, and every name in 64 bit objects, are stored as an
+		// offset into the strings table.
+		name := sym[:8]
+		if wide || binary.BigEndian.Uint32(name) == 0 {
+			start := int64(binary.BigEndian.Uint32(name[4:]))
+			if wide {
+				start = int64(binary.BigEndian.Uint32(sym[8:]))
+			}
+			if start >= int64(len(table)) {
+				return nil, errXCOFFObject
+			}
+
+			name = table[start:]
+		}
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,50 @@
+package ar
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestXCOFFSymbols(t *testing.T) {
+	in, err := os.Open(filepath.Join("testdata", "xcoff.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+
+	// The symbols llvm-ar adds to the index for the object.
+	names, err := xcoffSymbols(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{".hello", ".weakfn", "counter", "hidden_value", "hello", "weakfn", "common_buf"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Symbols are %v, expected %v.", names, expected)
+	}
+
+	// A 64 bit object with external, undefined, weak and hidden symbols.
+	in64, err := os.Open(filepath.Join("testdata", "xcoff64.o"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in64.Close()
+
+	names, err = xcoffSymbols(in64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []string{"wide_fn", "wide_data"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("Symbols are %v, expected %v.", names, expected)
+	}
+
+	_, err = xcoffSymbols(bytes.NewReader(xcoff64Magic))
+	if err != errXCOFFObject {
+		t.Error("Truncated headers should be invalid.")
+	}
+}